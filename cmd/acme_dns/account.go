@@ -0,0 +1,151 @@
+package acme_dns
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/sikalabs/slr/internal/file_utils"
+	"github.com/sikalabs/slr/internal/vault_utils"
+)
+
+// User implements registration.User for lego.
+type User struct {
+	Email        string
+	Registration *registration.Resource
+	key          crypto.PrivateKey
+}
+
+func (u *User) GetEmail() string                        { return u.Email }
+func (u *User) GetRegistration() *registration.Resource { return u.Registration }
+func (u *User) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// storedAccount is ACME account persisted between runs, so we don't
+// register new Let's Encrypt account every time. In Vault all values
+// are stored as strings (registration as JSON). Registration is valid
+// only for the CA directory it was created at.
+type storedAccount struct {
+	Email        string                 `json:"email"`
+	CADirURL     string                 `json:"caDirURL"`
+	Key          string                 `json:"key"`
+	Registration *registration.Resource `json:"registration"`
+}
+
+// accountStore loads and saves ACME account from file or Vault KV2,
+// empty store keeps the account only in memory
+type accountStore struct {
+	file       string
+	vaultAddr  string
+	vaultToken string
+	vaultPath  string
+}
+
+func (s accountStore) load() (*storedAccount, error) {
+	switch {
+	case s.file != "":
+		data, err := os.ReadFile(s.file)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var account storedAccount
+		if err := json.Unmarshal(data, &account); err != nil {
+			return nil, fmt.Errorf("failed to parse account file %s: %w", s.file, err)
+		}
+		return &account, nil
+	case s.vaultPath != "":
+		client, err := vault_utils.NewClient(s.vaultAddr, s.vaultToken)
+		if err != nil {
+			return nil, err
+		}
+		data, err := vault_utils.ReadKV2(client, s.vaultPath)
+		if vault_utils.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		account := storedAccount{Email: data["email"], CADirURL: data["caDirURL"], Key: data["key"]}
+		if data["registration"] != "" {
+			if err := json.Unmarshal([]byte(data["registration"]), &account.Registration); err != nil {
+				return nil, fmt.Errorf("failed to parse registration from vault: %w", err)
+			}
+		}
+		return &account, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (s accountStore) save(account *storedAccount) error {
+	switch {
+	case s.file != "":
+		data, err := json.MarshalIndent(account, "", "  ")
+		if err != nil {
+			return err
+		}
+		return file_utils.WriteFileAtomic(s.file, data, 0600, "", "")
+	case s.vaultPath != "":
+		client, err := vault_utils.NewClient(s.vaultAddr, s.vaultToken)
+		if err != nil {
+			return err
+		}
+		reg, err := json.Marshal(account.Registration)
+		if err != nil {
+			return err
+		}
+		return vault_utils.WriteKV2(client, s.vaultPath, map[string]string{
+			"email":        account.Email,
+			"caDirURL":     account.CADirURL,
+			"key":          account.Key,
+			"registration": string(reg),
+		})
+	default:
+		return nil
+	}
+}
+
+// loadOrCreateUser returns user with stored key and registration, or new
+// user with fresh key (registration is nil and must be done by caller)
+func loadOrCreateUser(store accountStore, email, caDirURL string) (*User, error) {
+	account, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if account != nil && account.Key != "" {
+		key, err := certcrypto.ParsePEMPrivateKey([]byte(account.Key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stored account key: %w", err)
+		}
+		user := &User{Email: email, key: key}
+		// Changed email or CA needs new registration, the key can be reused
+		if account.Email == email && account.CADirURL == caDirURL {
+			user.Registration = account.Registration
+		}
+		return user, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &User{Email: email, key: key}, nil
+}
+
+func saveUser(store accountStore, user *User, caDirURL string) error {
+	return store.save(&storedAccount{
+		Email:        user.Email,
+		CADirURL:     caDirURL,
+		Key:          string(certcrypto.PEMEncode(user.key)),
+		Registration: user.Registration,
+	})
+}
@@ -2,15 +2,16 @@ package acme_dns
 
 import (
 	"context"
-	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
@@ -18,11 +19,13 @@ import (
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/providers/dns/acmedns"
 	"github.com/go-acme/lego/v4/registration"
-	vault "github.com/hashicorp/vault/api"
 	"github.com/nrdcg/goacmedns"
-	goacmedns_storage "github.com/nrdcg/goacmedns/storage"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/file_utils"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slr/internal/time_utils"
+	"github.com/sikalabs/slr/internal/vault_utils"
 	"github.com/spf13/cobra"
 )
 
@@ -39,6 +42,29 @@ var FlagVaultAddr string
 var FlagVaultToken string
 var FlagVaultPath string
 var FlagStaging bool
+var FlagAcmeAccountFile string
+var FlagAcmeAccountVaultPath string
+var FlagRenewBefore string
+var FlagKeyType string
+var FlagResolvers []string
+var FlagRegisterAcmeDNS bool
+var FlagAllowFrom []string
+var FlagProvider string
+var FlagCADirURL string
+var FlagCACert string
+var FlagPropagationDisableANS bool
+var FlagChalltestsrvURL string
+var FlagK8sSecret string
+var FlagK8s *k8s.Options
+
+var keyTypes = map[string]certcrypto.KeyType{
+	"ec256":   certcrypto.EC256,
+	"ec384":   certcrypto.EC384,
+	"rsa2048": certcrypto.RSA2048,
+	"rsa3072": certcrypto.RSA3072,
+	"rsa4096": certcrypto.RSA4096,
+	"rsa8192": certcrypto.RSA8192,
+}
 
 func init() {
 	root.Cmd.AddCommand(Cmd)
@@ -51,156 +77,241 @@ func init() {
 	Cmd.Flags().StringVar(&FlagAccountSubDomain, "account-sub-domain", "", "ACME DNS account sub domain")
 	Cmd.Flags().StringVar(&FlagCertFile, "cert-file", "", "Output certificate file path")
 	Cmd.Flags().StringVar(&FlagKeyFile, "key-file", "", "Output private key file path")
-	Cmd.Flags().StringVar(&FlagVaultAddr, "vault-addr", "", "Vault server address (e.g. https://vault.example.com)")
-	Cmd.Flags().StringVar(&FlagVaultToken, "vault-token", "", "Vault token")
-	Cmd.Flags().StringVar(&FlagVaultPath, "vault-path", "", "Vault KV2 path to store certificate and key (e.g. secret/data/certs/mysite)")
+	Cmd.Flags().StringVar(&FlagVaultAddr, "vault-addr", "", "Vault server address (e.g. https://vault.example.com, default VAULT_ADDR)")
+	Cmd.Flags().StringVar(&FlagVaultToken, "vault-token", "", "Vault token (default VAULT_TOKEN or ~/.vault-token)")
+	Cmd.Flags().StringVar(&FlagVaultPath, "vault-path", "", "Vault KV2 path to store certificate and key (e.g. secret/certs/mysite)")
 	Cmd.Flags().BoolVar(&FlagStaging, "staging", false, "Use Let's Encrypt staging API")
+	Cmd.Flags().StringVar(&FlagAcmeAccountFile, "acme-account-file", "", "File to persist ACME account key and registration (JSON)")
+	Cmd.Flags().StringVar(&FlagAcmeAccountVaultPath, "acme-account-vault-path", "", "Vault KV2 path to persist ACME account key and registration")
+	Cmd.Flags().StringVar(&FlagRenewBefore, "renew-before", "", "Skip issuance if existing certificate (--cert-file or --vault-path) is valid longer than this (e.g. 30d)")
+	Cmd.Flags().StringVar(&FlagKeyType, "key-type", "rsa2048", "Certificate key type: ec256, ec384, rsa2048, rsa3072, rsa4096, rsa8192")
+	Cmd.Flags().StringSliceVar(&FlagResolvers, "resolvers", []string{"8.8.8.8:53"}, "DNS resolvers used for propagation check (empty for system resolvers)")
+	Cmd.Flags().BoolVar(&FlagRegisterAcmeDNS, "register-acme-dns", false, "Register new acme-dns account, print its credentials and CNAME records to add and exit")
+	Cmd.Flags().StringSliceVar(&FlagAllowFrom, "allow-from", nil, "CIDRs allowed to update the acme-dns account (with --register-acme-dns)")
+	Cmd.Flags().StringVar(&FlagProvider, "provider", ProviderAcmeDNS, "DNS-01 provider: acme-dns, challtestsrv or lego provider cloudflare, digitalocean, rfc2136, route53 configured by env")
+	Cmd.Flags().StringVar(&FlagCADirURL, "ca-dir-url", "", "ACME directory URL (default Let's Encrypt, e.g. https://localhost:14000/dir for Pebble)")
+	Cmd.Flags().StringVar(&FlagCACert, "ca-cert", "", "PEM file with CA certificate of ACME server (e.g. Pebble minica)")
+	Cmd.Flags().BoolVar(&FlagPropagationDisableANS, "propagation-disable-ans", false, "Check DNS propagation only on --resolvers, not on authoritative nameservers")
+	Cmd.Flags().StringVar(&FlagChalltestsrvURL, "challtestsrv-url", "http://localhost:8055", "pebble-challtestsrv management API (with --provider challtestsrv)")
+	Cmd.Flags().StringVar(&FlagK8sSecret, "k8s-secret", "", "Kubernetes TLS secret to store certificate and key as namespace/name")
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	// --k8s-context is kept for existing scripts, use --context
+	Cmd.Flags().StringVar(&FlagK8s.Context, "k8s-context", "", "Kubeconfig context for --k8s-secret (default current context)")
+	Cmd.Flags().MarkDeprecated("k8s-context", "use --context")
 	_ = Cmd.MarkFlagRequired("domains")
-	_ = Cmd.MarkFlagRequired("email")
-	_ = Cmd.MarkFlagRequired("account-username")
-	_ = Cmd.MarkFlagRequired("account-password")
-	_ = Cmd.MarkFlagRequired("account-full-domain")
-	_ = Cmd.MarkFlagRequired("account-sub-domain")
 }
 
 var Cmd = &cobra.Command{
 	Use:   "acme-dns",
-	Short: "Obtain ACME certificate using ACME DNS challenge with acme-dns provider",
+	Short: "Obtain ACME certificate using DNS-01 challenge (acme-dns, cloudflare, digitalocean, rfc2136, route53)",
 	Args:  cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
-		acme_dns(
-			FlagDomains,
-			FlagEmail,
-			FlagAcmeDNSAPIBase,
-			FlagAccountUsername,
-			FlagAccountPassword,
-			FlagAccountFullDomain,
-			FlagAccountSubDomain,
-			FlagCertFile,
-			FlagKeyFile,
-			FlagVaultAddr,
-			FlagVaultToken,
-			FlagVaultPath,
-			FlagStaging,
-		)
+		if FlagRegisterAcmeDNS {
+			registerAcmeDNS(FlagAcmeDNSAPIBase, FlagDomains, FlagAllowFrom)
+			return
+		}
+		required := []string{"email"}
+		if FlagProvider == ProviderAcmeDNS || FlagProvider == "acmedns" {
+			required = append(required, "account-username", "account-password", "account-full-domain", "account-sub-domain")
+		}
+		for _, f := range required {
+			if !c.Flags().Changed(f) {
+				log.Fatalf("required flag \"%s\" not set", f)
+			}
+		}
+		if err := acme_dns(optionsFromFlags()); err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
-// User implements registration.User for lego.
-type User struct {
-	Email        string
-	Registration *registration.Resource
-	key          *ecdsa.PrivateKey
-}
+func registerAcmeDNS(acmeDNSAPIBase string, domains []string, allowFrom []string) {
+	client, err := goacmedns.NewClient(acmeDNSAPIBase)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func (u *User) GetEmail() string                        { return u.Email }
-func (u *User) GetRegistration() *registration.Resource { return u.Registration }
-func (u *User) GetPrivateKey() crypto.PrivateKey        { return u.key }
+	account, err := client.RegisterAccount(context.Background(), allowFrom)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-// memoryStorage is an in-memory implementation of goacmedns.Storage
-// pre-populated with account data passed directly in code.
-type memoryStorage struct {
-	accounts map[string]goacmedns.Account
+	fmt.Printf("acme-dns account registered at %s\n\n", acmeDNSAPIBase)
+	fmt.Printf("  --account-username %s \\\n", account.Username)
+	fmt.Printf("  --account-password %s \\\n", account.Password)
+	fmt.Printf("  --account-full-domain %s \\\n", account.FullDomain)
+	fmt.Printf("  --account-sub-domain %s\n\n", account.SubDomain)
+	fmt.Println("Add following DNS records:")
+	fmt.Println()
+	for _, d := range domains {
+		fmt.Printf("  _acme-challenge.%s. CNAME %s.\n", strings.TrimPrefix(d, "*."), account.FullDomain)
+	}
 }
 
-func newMemoryStorage(accounts map[string]goacmedns.Account) *memoryStorage {
-	return &memoryStorage{accounts: accounts}
+// options of acme_dns, Cmd fills them from flags
+type options struct {
+	domains               []string
+	email                 string
+	acmeDNSAPIBase        string
+	accountUsername       string
+	accountPassword       string
+	accountFullDomain     string
+	accountSubDomain      string
+	certFile              string
+	keyFile               string
+	vaultAddr             string
+	vaultToken            string
+	vaultPath             string
+	staging               bool
+	acmeAccountFile       string
+	acmeAccountVaultPath  string
+	renewBefore           string
+	keyType               string
+	resolvers             []string
+	provider              string
+	caDirURL              string
+	caCert                string
+	propagationDisableANS bool
+	challtestsrvURL       string
+	k8sSecret             string
+	k8s                   *k8s.Options
 }
 
-func (m *memoryStorage) Save(_ context.Context) error { return nil }
-
-func (m *memoryStorage) Put(_ context.Context, domain string, account goacmedns.Account) error {
-	m.accounts[domain] = account
-	return nil
+func optionsFromFlags() options {
+	return options{
+		domains:               FlagDomains,
+		email:                 FlagEmail,
+		acmeDNSAPIBase:        FlagAcmeDNSAPIBase,
+		accountUsername:       FlagAccountUsername,
+		accountPassword:       FlagAccountPassword,
+		accountFullDomain:     FlagAccountFullDomain,
+		accountSubDomain:      FlagAccountSubDomain,
+		certFile:              FlagCertFile,
+		keyFile:               FlagKeyFile,
+		vaultAddr:             FlagVaultAddr,
+		vaultToken:            FlagVaultToken,
+		vaultPath:             FlagVaultPath,
+		staging:               FlagStaging,
+		acmeAccountFile:       FlagAcmeAccountFile,
+		acmeAccountVaultPath:  FlagAcmeAccountVaultPath,
+		renewBefore:           FlagRenewBefore,
+		keyType:               FlagKeyType,
+		resolvers:             FlagResolvers,
+		provider:              FlagProvider,
+		caDirURL:              FlagCADirURL,
+		caCert:                FlagCACert,
+		propagationDisableANS: FlagPropagationDisableANS,
+		challtestsrvURL:       FlagChalltestsrvURL,
+		k8sSecret:             FlagK8sSecret,
+		k8s:                   FlagK8s,
+	}
 }
 
-func (m *memoryStorage) Fetch(_ context.Context, domain string) (goacmedns.Account, error) {
-	account, ok := m.accounts[domain]
+func acme_dns(o options) error {
+	vaultEnabled := o.vaultPath != ""
+	fileEnabled := o.certFile != "" && o.keyFile != ""
+	k8sEnabled := o.k8sSecret != ""
+
+	if !vaultEnabled && !fileEnabled && !k8sEnabled {
+		return errors.New("at least one output must be configured: use --cert-file/--key-file, --vault-path or --k8s-secret")
+	}
+
+	keyType, ok := keyTypes[o.keyType]
 	if !ok {
-		return goacmedns.Account{}, goacmedns_storage.ErrDomainNotFound
+		return fmt.Errorf("unsupported --key-type %s", o.keyType)
 	}
-	return account, nil
-}
 
-func (m *memoryStorage) FetchAll(_ context.Context) (map[string]goacmedns.Account, error) {
-	return m.accounts, nil
-}
+	if o.renewBefore != "" {
+		renewBefore, err := time_utils.ParseDuration(o.renewBefore)
+		if err != nil {
+			return err
+		}
+		existing, err := readExistingCertificate(o.certFile, o.vaultAddr, o.vaultToken, o.vaultPath)
+		if err != nil {
+			return err
+		}
+		if existing == nil && k8sEnabled {
+			existing, err = readKubernetesCertificate(o.k8s, o.k8sSecret)
+			if err != nil {
+				return err
+			}
+		}
+		renew, reason := needsRenewal(existing, o.domains, renewBefore, time.Now())
+		if !renew {
+			fmt.Printf("Skipping issuance, %s\n", reason)
+			return nil
+		}
+		fmt.Printf("Renewing certificate, %s\n", reason)
+	}
 
-func acme_dns(
-	domains []string,
-	email string,
-	acmeDNSAPIBase string,
-	accountUsername string,
-	accountPassword string,
-	accountFullDomain string,
-	accountSubDomain string,
-	certFile string,
-	keyFile string,
-	vaultAddr string,
-	vaultToken string,
-	vaultPath string,
-	staging bool,
-) {
-	account := goacmedns.Account{
-		Username:   accountUsername,
-		Password:   accountPassword,
-		FullDomain: accountFullDomain,
-		SubDomain:  accountSubDomain,
+	store := accountStore{
+		file:       o.acmeAccountFile,
+		vaultAddr:  o.vaultAddr,
+		vaultToken: o.vaultToken,
+		vaultPath:  o.acmeAccountVaultPath,
 	}
-	accounts := make(map[string]goacmedns.Account, len(domains))
-	for _, d := range domains {
-		accounts[d] = account
+	caDirURL := lego.LEDirectoryProduction
+	switch {
+	case o.caDirURL != "":
+		caDirURL = o.caDirURL
+	case o.staging:
+		caDirURL = lego.LEDirectoryStaging
 	}
-
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	user, err := loadOrCreateUser(store, o.email, caDirURL)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	user := &User{Email: email, key: privateKey}
-
 	config := lego.NewConfig(user)
-	if staging {
-		config.CADirURL = lego.LEDirectoryStaging
-	} else {
-		config.CADirURL = lego.LEDirectoryProduction
+	config.CADirURL = caDirURL
+	config.Certificate.KeyType = keyType
+	if o.caCert != "" {
+		config.HTTPClient, err = httpClientWithCA(o.caCert)
+		if err != nil {
+			return err
+		}
 	}
-	config.Certificate.KeyType = certcrypto.RSA2048
 
 	client, err := lego.NewClient(config)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	goacmednsClient, err := goacmedns.NewClient(acmeDNSAPIBase)
-	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	store := newMemoryStorage(accounts)
-
-	// NewDNSProviderClient is used here to pass a custom in-memory storage.
-	//nolint:staticcheck
-	provider, err := acmedns.NewDNSProviderClient(goacmednsClient, store)
+	provider, err := newProvider(o.provider, o.domains, acmeDNSAccount{
+		apiBase: o.acmeDNSAPIBase,
+		account: goacmedns.Account{
+			Username:   o.accountUsername,
+			Password:   o.accountPassword,
+			FullDomain: o.accountFullDomain,
+			SubDomain:  o.accountSubDomain,
+		},
+	}, o.challtestsrvURL)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	err = client.Challenge.SetDNS01Provider(provider,
-		dns01.AddRecursiveNameservers([]string{"8.8.8.8:53"}),
+		dns01.CondOption(len(o.resolvers) > 0, dns01.AddRecursiveNameservers(normalizeResolvers(o.resolvers))),
+		dns01.CondOption(o.propagationDisableANS, dns01.DisableAuthoritativeNssPropagationRequirement()),
 	)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-	if err != nil {
-		log.Fatal(err)
+	if user.Registration == nil {
+		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return err
+		}
+		user.Registration = reg
+		if err := saveUser(store, user, caDirURL); err != nil {
+			return fmt.Errorf("failed to save ACME account: %w", err)
+		}
+		fmt.Printf("ACME account registered: %s\n", reg.URI)
 	}
-	user.Registration = reg
 
 	request := certificate.ObtainRequest{
-		Domains: domains,
+		Domains: o.domains,
 		Bundle:  true,
 	}
 
@@ -208,55 +319,80 @@ func acme_dns(
 	if err != nil {
 		var cnameErr acmedns.ErrCNAMERequired
 		if errors.As(err, &cnameErr) {
-			log.Fatalf("CNAME setup required for %q:\n  %s CNAME %s.\nAdd this record and re-run.", cnameErr.Domain, cnameErr.FQDN, cnameErr.Target)
+			return fmt.Errorf("CNAME setup required for %q:\n  %s CNAME %s.\nAdd this record and re-run.", cnameErr.Domain, cnameErr.FQDN, cnameErr.Target)
 		}
-		log.Fatal(err)
-	}
-
-	vaultEnabled := vaultAddr != "" && vaultToken != "" && vaultPath != ""
-	fileEnabled := certFile != "" && keyFile != ""
-
-	if !vaultEnabled && !fileEnabled {
-		log.Fatal("at least one output must be configured: use --cert-file/--key-file, --vault-addr/--vault-token/--vault-path, or both")
+		return err
 	}
 
-	fmt.Printf("Certificate obtained for: %s\n", strings.Join(domains, ", "))
+	fmt.Printf("Certificate obtained for: %s\n", strings.Join(o.domains, ", "))
 	fmt.Printf("Certificate URL: %s\n", cert.CertURL)
 
 	if fileEnabled {
-		if err := os.WriteFile(certFile, cert.Certificate, 0644); err != nil {
-			log.Fatal(err)
+		if err := file_utils.WriteFileAtomic(o.certFile, cert.Certificate, 0644, "", ""); err != nil {
+			return err
 		}
-		if err := os.WriteFile(keyFile, cert.PrivateKey, 0600); err != nil {
-			log.Fatal(err)
+		if err := file_utils.WriteFileAtomic(o.keyFile, cert.PrivateKey, 0600, "", ""); err != nil {
+			return err
 		}
-		fmt.Printf("Certificate saved to: %s\n", certFile)
-		fmt.Printf("Private key saved to: %s\n", keyFile)
+		fmt.Printf("Certificate saved to: %s\n", o.certFile)
+		fmt.Printf("Private key saved to: %s\n", o.keyFile)
 	}
 
 	if vaultEnabled {
-		vaultConfig := vault.DefaultConfig()
-		vaultConfig.Address = vaultAddr
-		vaultClient, err := vault.NewClient(vaultConfig)
+		vaultClient, err := vault_utils.NewClient(o.vaultAddr, o.vaultToken)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		vaultClient.SetToken(vaultToken)
 
 		// vaultPath format: <mount>/<secret-path>, e.g. "secret/certs/mysite"
-		parts := strings.SplitN(vaultPath, "/", 2)
-		if len(parts) != 2 {
-			log.Fatalf("--vault-path must be in format <mount>/<secret-path>, got: %s", vaultPath)
-		}
-		vaultMount, vaultSecretPath := parts[0], parts[1]
-
-		_, err = vaultClient.KVv2(vaultMount).Put(context.Background(), vaultSecretPath, map[string]interface{}{
+		err = vault_utils.WriteKV2(vaultClient, o.vaultPath, map[string]string{
 			"tls.crt": string(cert.Certificate),
 			"tls.key": string(cert.PrivateKey),
 		})
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		fmt.Printf("Certificate stored in Vault at: %s\n", o.vaultPath)
+	}
+
+	if k8sEnabled {
+		err := writeKubernetesSecret(o.k8s, o.k8sSecret, cert.Certificate, cert.PrivateKey)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Certificate stored in Kubernetes secret: %s\n", o.k8sSecret)
+	}
+	return nil
+}
+
+// httpClientWithCA returns HTTP client for ACME server signed by private
+// CA (Pebble), system roots are trusted as well
+func httpClientWithCA(caFile string) (*http.Client, error) {
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Timeout: 2 * time.Minute, Transport: transport}, nil
+}
+
+// normalizeResolvers adds default DNS port to resolvers given as IP only
+func normalizeResolvers(resolvers []string) []string {
+	out := make([]string, 0, len(resolvers))
+	for _, r := range resolvers {
+		if _, _, err := net.SplitHostPort(r); err != nil {
+			r = net.JoinHostPort(r, "53")
 		}
-		fmt.Printf("Certificate stored in Vault at: %s\n", vaultPath)
+		out = append(out, r)
 	}
+	return out
 }
@@ -0,0 +1,82 @@
+package acme_dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sikalabs/slr/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// parseSecretRef parses namespace/name of --k8s-secret
+func parseSecretRef(ref string) (string, string, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("--k8s-secret must be in format namespace/name, got: %s", ref)
+	}
+	return namespace, name, nil
+}
+
+// readKubernetesCertificate returns tls.crt of the secret, nil if the
+// secret doesn't exist yet
+func readKubernetesCertificate(k8sOptions *k8s.Options, ref string) ([]byte, error) {
+	namespace, name, err := parseSecretRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := k8sOptions.Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data[corev1.TLSCertKey], nil
+}
+
+// writeKubernetesSecret creates or updates kubernetes.io/tls secret, the
+// same secret cert-manager would create for an Ingress
+func writeKubernetesSecret(k8sOptions *k8s.Options, ref string, cert, key []byte) error {
+	namespace, name, err := parseSecretRef(ref)
+	if err != nil {
+		return err
+	}
+	clientset, err := k8sOptions.Clientset()
+	if err != nil {
+		return err
+	}
+	secrets := clientset.CoreV1().Secrets(namespace)
+
+	secret, err := secrets.Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       cert,
+				corev1.TLSPrivateKeyKey: key,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[corev1.TLSCertKey] = cert
+	secret.Data[corev1.TLSPrivateKeyKey] = key
+	_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,129 @@
+//go:build pebble
+
+package acme_dns
+
+// Integration test against local Pebble ACME server and
+// pebble-challtestsrv (see examples/slr_acme_dns_pebble), run with:
+//
+//	make -C examples/slr_acme_dns_pebble up
+//	go test -tags pebble ./cmd/acme_dns/
+//
+// PEBBLE_DIR_URL, CHALLTESTSRV_URL and CHALLTESTSRV_DNS override defaults.
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pebbleCACert = "../../examples/slr_acme_dns_pebble/pebble.minica.pem"
+
+func getenv(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// runAcmeDNS runs acme_dns with o and returns its stdout
+func runAcmeDNS(t *testing.T, o options) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		out <- buf.String()
+	}()
+
+	err = acme_dns(o)
+	os.Stdout = stdout
+	w.Close()
+	output := <-out
+	if err != nil {
+		t.Fatalf("acme_dns: %v, output:\n%s", err, output)
+	}
+	return output
+}
+
+func TestPebbleIssueAndSkipRenewal(t *testing.T) {
+	dirURL := getenv("PEBBLE_DIR_URL", "https://localhost:14000/dir")
+	challtestsrvURL := getenv("CHALLTESTSRV_URL", "http://localhost:8055")
+	challtestsrvDNS := getenv("CHALLTESTSRV_DNS", "127.0.0.1:8053")
+
+	u, err := url.Parse(dirURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		t.Skipf("Pebble is not available at %s: %v", dirURL, err)
+	}
+	conn.Close()
+
+	dir := t.TempDir()
+	domain := "test.example.com"
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	o := options{
+		domains:               []string{domain},
+		email:                 "test@example.com",
+		certFile:              certFile,
+		keyFile:               keyFile,
+		acmeAccountFile:       filepath.Join(dir, "account.json"),
+		keyType:               "ec256",
+		resolvers:             []string{challtestsrvDNS},
+		provider:              ProviderChalltestsrv,
+		caDirURL:              dirURL,
+		caCert:                pebbleCACert,
+		propagationDisableANS: true,
+		challtestsrvURL:       challtestsrvURL,
+	}
+
+	out := runAcmeDNS(t, o)
+	if !strings.Contains(out, "Certificate obtained for: "+domain) {
+		t.Fatalf("certificate not obtained, output:\n%s", out)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(leaf.DNSNames, domain) {
+		t.Fatalf("certificate is for %v, want %s", leaf.DNSNames, domain)
+	}
+	issued, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.renewBefore = "1d"
+	out = runAcmeDNS(t, o)
+	if !strings.Contains(out, "Skipping issuance") {
+		t.Fatalf("renewal not skipped, output:\n%s", out)
+	}
+	current, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(issued, current) {
+		t.Fatal("certificate was rewritten by skipped renewal")
+	}
+}
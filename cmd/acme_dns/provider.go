@@ -0,0 +1,148 @@
+package acme_dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/providers/dns/acmedns"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/digitalocean"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"github.com/nrdcg/goacmedns"
+	goacmedns_storage "github.com/nrdcg/goacmedns/storage"
+)
+
+const ProviderAcmeDNS = "acme-dns"
+
+// ProviderChalltestsrv sets TXT records in pebble-challtestsrv, it is
+// used to verify the whole flow offline against local Pebble ACME server
+const ProviderChalltestsrv = "challtestsrv"
+
+// dnsProviders are lego DNS providers configured from environment
+// variables (e.g. cloudflare: CLOUDFLARE_DNS_API_TOKEN, see
+// https://go-acme.github.io/lego/dns/). Only these are linked in, the
+// whole lego registry pulls in SDKs of every DNS hosting.
+var dnsProviders = map[string]func() (challenge.Provider, error){
+	"cloudflare":   func() (challenge.Provider, error) { return cloudflare.NewDNSProvider() },
+	"digitalocean": func() (challenge.Provider, error) { return digitalocean.NewDNSProvider() },
+	"rfc2136":      func() (challenge.Provider, error) { return rfc2136.NewDNSProvider() },
+	"route53":      func() (challenge.Provider, error) { return route53.NewDNSProvider() },
+}
+
+// providerNames returns all names accepted by --provider
+func providerNames() []string {
+	names := []string{ProviderAcmeDNS, ProviderChalltestsrv}
+	for name := range dnsProviders {
+		names = append(names, name)
+	}
+	slices.Sort(names[2:])
+	return names
+}
+
+type acmeDNSAccount struct {
+	apiBase string
+	account goacmedns.Account
+}
+
+// newProvider returns DNS-01 provider by name. acme-dns uses account
+// from flags, challtestsrv uses its management API, other names are
+// looked up in dnsProviders
+func newProvider(name string, domains []string, acmeDNS acmeDNSAccount, challtestsrvURL string) (challenge.Provider, error) {
+	switch name {
+	case ProviderAcmeDNS, "acmedns":
+		accounts := make(map[string]goacmedns.Account, len(domains))
+		for _, d := range domains {
+			accounts[d] = acmeDNS.account
+		}
+
+		client, err := goacmedns.NewClient(acmeDNS.apiBase)
+		if err != nil {
+			return nil, err
+		}
+
+		// NewDNSProviderClient is used here to pass a custom in-memory storage.
+		//nolint:staticcheck
+		return acmedns.NewDNSProviderClient(client, newMemoryStorage(accounts))
+	case ProviderChalltestsrv:
+		return &challtestsrvProvider{url: strings.TrimRight(challtestsrvURL, "/")}, nil
+	default:
+		newDNSProvider, ok := dnsProviders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown DNS provider %q, supported providers: %s", name, strings.Join(providerNames(), ", "))
+		}
+		return newDNSProvider()
+	}
+}
+
+// memoryStorage is an in-memory implementation of goacmedns.Storage
+// pre-populated with account data passed directly in code.
+type memoryStorage struct {
+	accounts map[string]goacmedns.Account
+}
+
+func newMemoryStorage(accounts map[string]goacmedns.Account) *memoryStorage {
+	return &memoryStorage{accounts: accounts}
+}
+
+func (m *memoryStorage) Save(_ context.Context) error { return nil }
+
+func (m *memoryStorage) Put(_ context.Context, domain string, account goacmedns.Account) error {
+	m.accounts[domain] = account
+	return nil
+}
+
+func (m *memoryStorage) Fetch(_ context.Context, domain string) (goacmedns.Account, error) {
+	account, ok := m.accounts[domain]
+	if !ok {
+		return goacmedns.Account{}, goacmedns_storage.ErrDomainNotFound
+	}
+	return account, nil
+}
+
+func (m *memoryStorage) FetchAll(_ context.Context) (map[string]goacmedns.Account, error) {
+	return m.accounts, nil
+}
+
+// challtestsrvProvider implements challenge.Provider using management
+// API of pebble-challtestsrv (https://github.com/letsencrypt/pebble)
+type challtestsrvProvider struct {
+	url string
+}
+
+func (p *challtestsrvProvider) Present(domain, token, keyAuth string) error {
+	info := dns01.GetChallengeInfo(domain, keyAuth)
+	return p.post("/set-txt", map[string]string{"host": info.EffectiveFQDN, "value": info.Value})
+}
+
+func (p *challtestsrvProvider) CleanUp(domain, token, keyAuth string) error {
+	info := dns01.GetChallengeInfo(domain, keyAuth)
+	return p.post("/clear-txt", map[string]string{"host": info.EffectiveFQDN})
+}
+
+func (p *challtestsrvProvider) post(path string, payload map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(p.url+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("challtestsrv %s returned %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package acme_dns
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/sikalabs/slr/internal/vault_utils"
+)
+
+// readExistingCertificate reads current certificate from --cert-file or
+// from --vault-path (tls.crt), nil is returned if there is none yet
+func readExistingCertificate(certFile, vaultAddr, vaultToken, vaultPath string) ([]byte, error) {
+	if certFile != "" {
+		data, err := os.ReadFile(certFile)
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if vaultPath != "" {
+		client, err := vault_utils.NewClient(vaultAddr, vaultToken)
+		if err != nil {
+			return nil, err
+		}
+		data, err := vault_utils.ReadKV2(client, vaultPath)
+		if err != nil && !vault_utils.IsNotFound(err) {
+			return nil, err
+		}
+		if data["tls.crt"] != "" {
+			return []byte(data["tls.crt"]), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// needsRenewal returns false only if existing certificate covers all
+// domains and is valid for longer than renewBefore
+func needsRenewal(existing []byte, domains []string, renewBefore time.Duration, now time.Time) (bool, string) {
+	if existing == nil {
+		return true, "no existing certificate"
+	}
+
+	cert, err := certcrypto.ParsePEMCertificate(existing)
+	if err != nil {
+		return true, fmt.Sprintf("existing certificate can't be parsed: %v", err)
+	}
+
+	certDomains := certcrypto.ExtractDomains(cert)
+	for _, d := range domains {
+		if !slices.Contains(certDomains, d) {
+			return true, fmt.Sprintf("existing certificate doesn't cover %s", d)
+		}
+	}
+
+	left := cert.NotAfter.Sub(now)
+	if left < renewBefore {
+		return true, fmt.Sprintf("existing certificate expires in %d days", int(left.Hours()/24))
+	}
+
+	return false, fmt.Sprintf("existing certificate is valid until %s (%d days)", cert.NotAfter.Format(time.RFC3339), int(left.Hours()/24))
+}
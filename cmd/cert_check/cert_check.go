@@ -0,0 +1,226 @@
+package cert_check
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slu/utils/mail_utils"
+	"github.com/sikalabs/slu/utils/telegram_utils"
+	"github.com/spf13/cobra"
+)
+
+var FlagFiles []string
+var FlagK8sSecrets []string
+var FlagK8sNamespace string
+var FlagK8sAllNamespaces bool
+var FlagK8s *k8s.Options
+var FlagVaultAddr string
+var FlagVaultPaths []string
+var FlagHosts []string
+var FlagCAFile string
+var FlagTimeout int
+var FlagWarnDays int
+var FlagSmtpHost string
+var FlagSmtpPort int
+var FlagSmtpUser string
+var FlagSmtpPassword string
+var FlagMailFrom string
+var FlagMailTo string
+var FlagTelegramBotToken string
+var FlagTelegramChatID string
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringSliceVarP(&FlagFiles, "file", "f", nil, "PEM certificate file, optionally with key as cert.pem:key.pem")
+	Cmd.Flags().StringSliceVar(&FlagK8sSecrets, "k8s-secret", nil, "Kubernetes TLS secret as namespace/name")
+	Cmd.Flags().StringVar(&FlagK8sNamespace, "k8s-namespace", "", "Check all TLS secrets in Kubernetes namespace")
+	Cmd.Flags().BoolVar(&FlagK8sAllNamespaces, "k8s-all-namespaces", false, "Check all TLS secrets in Kubernetes cluster")
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	Cmd.Flags().StringVar(&FlagVaultAddr, "vault-addr", "", "Vault address (default VAULT_ADDR)")
+	Cmd.Flags().StringSliceVar(&FlagVaultPaths, "vault-path", nil, "Vault KV2 path with tls.crt and tls.key (e.g. secret/certs/mysite)")
+	Cmd.Flags().StringSliceVarP(&FlagHosts, "host", "H", nil, "Live endpoint as host:port (port defaults to 443)")
+	Cmd.Flags().StringVar(&FlagCAFile, "ca-file", "", "Additional trusted CA certificates (PEM) for chain verification")
+	Cmd.Flags().IntVarP(&FlagTimeout, "timeout", "t", 10, "Timeout in seconds for connecting to hosts")
+	Cmd.Flags().IntVarP(&FlagWarnDays, "warn-days", "w", 14, "Fail if certificate expires in less than given days")
+	Cmd.Flags().StringVar(&FlagSmtpHost, "smtp-host", "", "SMTP host")
+	Cmd.Flags().IntVar(&FlagSmtpPort, "smtp-port", 587, "SMTP port")
+	Cmd.Flags().StringVar(&FlagSmtpUser, "smtp-user", "", "SMTP user (defaults to --mail-from)")
+	Cmd.Flags().StringVar(&FlagSmtpPassword, "smtp-password", "", "SMTP password")
+	Cmd.Flags().StringVar(&FlagMailFrom, "mail-from", "", "Email sender address")
+	Cmd.Flags().StringVar(&FlagMailTo, "mail-to", "", "Email recipient address (comma-separated for multiple)")
+	Cmd.Flags().StringVar(&FlagTelegramBotToken, "bot-token", "", "Telegram bot token for notifications")
+	Cmd.Flags().StringVar(&FlagTelegramChatID, "chat-id", "", "Telegram chat ID for notifications")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "cert-check",
+	Short: "Inspect TLS certificates from files, Kubernetes, Vault or live hosts and check expiry",
+	Args:  cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		ok := certCheck()
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+func certCheck() bool {
+	targets := fileTargets(FlagFiles)
+
+	k8sTargets, err := kubernetesTargets(FlagK8s, FlagK8sSecrets, FlagK8sNamespace, FlagK8sAllNamespaces)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: kubernetes: %v\n", err)
+		return false
+	}
+	targets = append(targets, k8sTargets...)
+
+	vaultTargets, err := vaultTargets(FlagVaultAddr, FlagVaultPaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: vault: %v\n", err)
+		return false
+	}
+	targets = append(targets, vaultTargets...)
+
+	targets = append(targets, hostTargets(FlagHosts, time.Duration(FlagTimeout)*time.Second)...)
+
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: nothing to check, use --file, --k8s-*, --vault-path or --host")
+		return false
+	}
+
+	if FlagCAFile != "" {
+		ca, err := os.ReadFile(FlagCAFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+		for i := range targets {
+			targets[i].CA = append(targets[i].CA, ca...)
+		}
+	}
+
+	now := time.Now()
+	var failed []Result
+	for _, t := range targets {
+		r := inspect(t, FlagWarnDays, now)
+		printResult(r)
+		if !r.OK() {
+			failed = append(failed, r)
+		}
+	}
+
+	if len(failed) > 0 {
+		notify(failed)
+		return false
+	}
+	return true
+}
+
+func printResult(r Result) {
+	status := "✅"
+	if !r.OK() {
+		status = "❌"
+	}
+	fmt.Printf("%s %s\n", status, r.Name)
+	if r.Err != nil {
+		fmt.Printf("  Error:     %v\n", r.Err)
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("  Subject:   %s\n", r.Subject)
+	fmt.Printf("  SANs:      %s\n", strings.Join(r.SANs, ", "))
+	fmt.Printf("  Issuer:    %s\n", r.Issuer)
+	fmt.Printf("  Not After: %s (%d days)\n", r.NotAfter.Format(time.RFC3339), r.DaysLeft)
+	if r.ChainErr != nil {
+		fmt.Printf("  Chain:     INVALID (%v)\n", r.ChainErr)
+	} else {
+		fmt.Printf("  Chain:     OK\n")
+	}
+	switch {
+	case r.KeyMatch == nil:
+		fmt.Printf("  Key:       -\n")
+	case *r.KeyMatch:
+		fmt.Printf("  Key:       OK (matches certificate)\n")
+	default:
+		fmt.Printf("  Key:       MISMATCH\n")
+	}
+	fmt.Println()
+}
+
+func summary(r Result) string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s: %v", r.Name, r.Err)
+	case r.ChainErr != nil:
+		return fmt.Sprintf("%s: invalid chain: %v", r.Name, r.ChainErr)
+	case r.KeyMatch != nil && !*r.KeyMatch:
+		return fmt.Sprintf("%s: key does not match certificate", r.Name)
+	default:
+		return fmt.Sprintf("%s: expires in %d days (%s)", r.Name, r.DaysLeft, r.NotAfter.Format("2006-01-02"))
+	}
+}
+
+func notify(failed []Result) {
+	sort.Slice(failed, func(i, j int) bool { return failed[i].DaysLeft < failed[j].DaysLeft })
+
+	lines := make([]string, 0, len(failed))
+	for _, r := range failed {
+		lines = append(lines, summary(r))
+	}
+	subject := fmt.Sprintf("Certificate check failed: %d certificate(s)", len(failed))
+	message := strings.Join(lines, "\n")
+
+	notifyMail(subject, message)
+	notifyTelegram(subject + "\n\n" + message)
+}
+
+func notifyMail(subject, message string) {
+	if FlagSmtpHost == "" || FlagMailFrom == "" || FlagMailTo == "" {
+		return
+	}
+	user := FlagMailFrom
+	if FlagSmtpUser != "" {
+		user = FlagSmtpUser
+	}
+	for _, to := range strings.Split(FlagMailTo, ",") {
+		to = strings.TrimSpace(to)
+		if to == "" {
+			continue
+		}
+		err := mail_utils.SendSimpleMail(
+			FlagSmtpHost,
+			strconv.Itoa(FlagSmtpPort),
+			user,
+			FlagSmtpPassword,
+			FlagMailFrom,
+			to,
+			subject,
+			message,
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send email notification to %s: %v\n", to, err)
+		}
+	}
+}
+
+func notifyTelegram(message string) {
+	if FlagTelegramBotToken == "" || FlagTelegramChatID == "" {
+		return
+	}
+	chatID, err := strconv.ParseInt(FlagTelegramChatID, 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid Telegram chat ID: %v\n", err)
+		return
+	}
+	err = telegram_utils.TelegramSendMessage(FlagTelegramBotToken, chatID, message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send Telegram notification: %v\n", err)
+	}
+}
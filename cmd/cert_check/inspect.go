@@ -0,0 +1,110 @@
+package cert_check
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Result struct {
+	Name      string
+	Subject   string
+	SANs      []string
+	Issuer    string
+	NotAfter  time.Time
+	DaysLeft  int
+	ChainErr  error
+	KeyMatch  *bool
+	Err       error
+	BelowWarn bool
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil && r.ChainErr == nil && !r.BelowWarn && (r.KeyMatch == nil || *r.KeyMatch)
+}
+
+func inspect(t Target, warnDays int, now time.Time) Result {
+	r := Result{Name: t.Name}
+	if t.Err != nil {
+		r.Err = t.Err
+		return r
+	}
+
+	chain, err := parseCertificates(t.Chain)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	leaf := chain[0]
+
+	r.Subject = leaf.Subject.String()
+	r.Issuer = leaf.Issuer.String()
+	r.SANs = append(r.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		r.SANs = append(r.SANs, ip.String())
+	}
+	r.NotAfter = leaf.NotAfter
+	r.DaysLeft = int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+	r.BelowWarn = r.DaysLeft < warnDays
+
+	r.ChainErr = verifyChain(chain, t.CA, t.ServerName, now)
+
+	if len(t.Key) > 0 {
+		_, err := tls.X509KeyPair(t.Chain, t.Key)
+		match := err == nil
+		r.KeyMatch = &match
+	}
+
+	return r
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// verifyChain verifies leaf against system roots (plus CA from ca.crt if
+// present) using the rest of the chain as intermediates
+func verifyChain(chain []*x509.Certificate, ca []byte, serverName string, now time.Time) error {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if len(ca) > 0 {
+		roots.AppendCertsFromPEM(ca)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	return err
+}
@@ -0,0 +1,153 @@
+package cert_check
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slr/internal/vault_utils"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Target is certificate chain (leaf first) from one source, Key and CA
+// are optional
+type Target struct {
+	Name       string
+	Chain      []byte
+	Key        []byte
+	CA         []byte
+	ServerName string
+	Err        error
+}
+
+// fileTargets parses --file values in format cert.pem or cert.pem:key.pem
+func fileTargets(files []string) []Target {
+	var targets []Target
+	for _, f := range files {
+		certFile, keyFile, _ := strings.Cut(f, ":")
+		t := Target{Name: "file " + certFile}
+		t.Chain, t.Err = os.ReadFile(certFile)
+		if t.Err == nil && keyFile != "" {
+			t.Key, t.Err = os.ReadFile(keyFile)
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// kubernetesTargets loads TLS secrets given as namespace/name, all TLS
+// secrets from namespace or all TLS secrets from cluster (allNamespaces)
+func kubernetesTargets(k8sOptions *k8s.Options, secrets []string, namespace string, allNamespaces bool) ([]Target, error) {
+	if len(secrets) == 0 && namespace == "" && !allNamespaces {
+		return nil, nil
+	}
+
+	clientset, err := k8sOptions.Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Target
+	for _, s := range secrets {
+		ns, name, ok := strings.Cut(s, "/")
+		if !ok {
+			return nil, fmt.Errorf("--k8s-secret must be in format namespace/name, got: %s", s)
+		}
+		secret, err := clientset.CoreV1().Secrets(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			targets = append(targets, Target{Name: "k8s " + s, Err: err})
+			continue
+		}
+		targets = append(targets, secretTarget(secret))
+	}
+
+	if namespace != "" || allNamespaces {
+		if allNamespaces {
+			namespace = metav1.NamespaceAll
+		}
+		list, err := clientset.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{
+			FieldSelector: "type=" + string(corev1.SecretTypeTLS),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			targets = append(targets, secretTarget(&list.Items[i]))
+		}
+	}
+
+	return targets, nil
+}
+
+func secretTarget(secret *corev1.Secret) Target {
+	return Target{
+		Name:  fmt.Sprintf("k8s %s/%s", secret.Namespace, secret.Name),
+		Chain: secret.Data[corev1.TLSCertKey],
+		Key:   secret.Data[corev1.TLSPrivateKeyKey],
+		CA:    secret.Data[corev1.ServiceAccountRootCAKey],
+	}
+}
+
+// vaultTargets reads KV2 secrets with tls.crt and tls.key keys
+func vaultTargets(vaultAddr string, paths []string) ([]Target, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	client, err := vault_utils.NewClient(vaultAddr, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Target
+	for _, p := range paths {
+		t := Target{Name: "vault " + p}
+		data, err := vault_utils.ReadKV2(client, p)
+		if err != nil {
+			t.Err = err
+		} else {
+			t.Chain = []byte(data[corev1.TLSCertKey])
+			t.Key = []byte(data[corev1.TLSPrivateKeyKey])
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// hostTargets fetches chain presented by live endpoints, the chain is
+// verified later, so the handshake itself doesn't verify anything
+func hostTargets(hosts []string, timeout time.Duration) []Target {
+	var targets []Target
+	for _, h := range hosts {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, "443")
+		}
+		host, _, _ := net.SplitHostPort(h)
+		t := Target{Name: "host " + h, ServerName: host}
+
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", h, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Err = err
+			targets = append(targets, t)
+			continue
+		}
+		for _, c := range conn.ConnectionState().PeerCertificates {
+			t.Chain = append(t.Chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+		}
+		conn.Close()
+		targets = append(targets, t)
+	}
+	return targets
+}
@@ -0,0 +1,149 @@
+package client_ip_web_server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// defaultTrustedProxies are loopback only, proxies in other networks
+// (like ingress controller in the cluster) must be added explicitly
+var defaultTrustedProxies = []string{
+	"127.0.0.0/8",
+	"::1/128",
+}
+
+// clientIPHeaders are headers supported by --client-ip-header
+var clientIPHeaders = []string{
+	"X-Forwarded-For",
+	"Forwarded",
+	"X-Real-IP",
+}
+
+var trustedProxies []netip.Prefix
+var clientIPHeader string
+
+// parseClientIPHeader returns canonical name of supported header
+func parseClientIPHeader(value string) (string, error) {
+	for _, h := range clientIPHeaders {
+		if strings.EqualFold(value, h) {
+			return h, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported header %s, use one of %s", value, strings.Join(clientIPHeaders, ", "))
+}
+
+// parseTrustedProxies accepts CIDRs and single IPs
+func parseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func trusted(addr netip.Addr) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns IP of the client and the proxy chain from headers.
+// Headers are used only if the peer is a trusted proxy. The chain is
+// walked from the right (the nearest proxy) and the first untrusted
+// address is the client, if all are trusted, the leftmost one is.
+func clientIP(r *http.Request) (string, []string) {
+	remote, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr, nil
+	}
+	if !trusted(remote) {
+		return remote.String(), nil
+	}
+
+	hops := forwardedHops(r.Header, clientIPHeader)
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			// unknown or obfuscated identifier, addresses left of it can't
+			// be trusted
+			break
+		}
+		client = addr
+		if !trusted(addr) {
+			break
+		}
+	}
+	return client.String(), hops
+}
+
+// forwardedHops returns addresses from the single configured header
+// (Forwarded "for" parameters, X-Forwarded-For or X-Real-IP), from the
+// client to the nearest proxy. Other headers are ignored, a client could
+// set them and the proxy would pass them through untouched.
+func forwardedHops(header http.Header, name string) []string {
+	hops := []string{}
+	switch name {
+	case "Forwarded":
+		for _, value := range header.Values("Forwarded") {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hops = append(hops, strings.Trim(val, `"`))
+					}
+				}
+			}
+		}
+	case "X-Real-IP":
+		if ip := strings.TrimSpace(header.Get("X-Real-IP")); ip != "" {
+			hops = append(hops, ip)
+		}
+	default:
+		for _, value := range header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses IP with optional port: 192.0.2.1, 192.0.2.1:443,
+// 2001:db8::1 or [2001:db8::1]:443
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
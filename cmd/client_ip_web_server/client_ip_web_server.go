@@ -1,47 +1,112 @@
 package client_ip_web_server
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/httpserver"
 	"github.com/spf13/cobra"
 )
 
+var FlagServer *httpserver.Options
+var FlagTrustedProxies []string
+var FlagClientIPHeader string
+var FlagGeoIPDB string
+var FlagASNDB string
+
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	})
+	Cmd.Flags().StringSliceVar(&FlagTrustedProxies, "trusted-proxies", defaultTrustedProxies, "CIDRs or IPs of proxies allowed to set --client-ip-header (empty to trust none)")
+	Cmd.Flags().StringVar(&FlagClientIPHeader, "client-ip-header", "X-Forwarded-For", "Header with client IP set by trusted proxy: X-Forwarded-For, Forwarded or X-Real-IP")
+	Cmd.Flags().StringVar(&FlagGeoIPDB, "geoip-db", "", "MaxMind GeoIP2/GeoLite2 City or Country mmdb file for /ip.json")
+	Cmd.Flags().StringVar(&FlagASNDB, "asn-db", "", "MaxMind GeoLite2 ASN mmdb file for /ip.json")
 }
 
 var Cmd = &cobra.Command{
 	Use:   "client-ip-web-server",
 	Short: "Webserver which prints out a client IP",
-	Args:  cobra.NoArgs,
+	Long: `Webserver which prints out a client IP.
+
+Client IP is resolved from --client-ip-header (X-Forwarded-For,
+Forwarded (RFC 7239) or X-Real-IP) only if the request comes from
+--trusted-proxies, the first untrusted address from the right is the
+client. Only loopback is trusted by default, widen it for proxies in
+other networks, for example ingress controller in the cluster:
+
+  slr client-ip-web-server --trusted-proxies 10.0.0.0/8
+
+  GET /         client IP
+  GET /ip.json  client IP, proxy chain and optional GeoIP and ASN
+  ANY /echo     method, headers, TLS, query and body of the request`,
+	Args: cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
+		var err error
+		trustedProxies, err = parseTrustedProxies(FlagTrustedProxies)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --trusted-proxies: %v\n", err)
+			os.Exit(1)
+		}
+		clientIPHeader, err = parseClientIPHeader(FlagClientIPHeader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --client-ip-header: %v\n", err)
+			os.Exit(1)
+		}
+		if err := openGeoIP(FlagGeoIPDB, FlagASNDB); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		server()
 	},
 }
 
 func server() {
-	http.HandleFunc("/", handler)
-	port := ":8000"
-	fmt.Println("Listen on 0.0.0.0:8000, see http://127.0.0.1:8000")
-	http.ListenAndServe(port, nil)
-}
-
-func getIP(r *http.Request) string {
-	// Try getting IP from headers (useful when behind a proxy)
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.Header.Get("X-Real-IP")
-	}
-	if ip == "" {
-		// Fallback to remote address
-		ip = r.RemoteAddr
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	mux.HandleFunc("GET /ip.json", ipJSONHandler)
+	mux.HandleFunc("/echo", echoHandler)
+	if err := FlagServer.ListenAndServe(mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	return ip
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
-	ip := getIP(r)
+	ip, _ := clientIP(r)
 	fmt.Fprintf(w, "%s\n", ip)
 }
+
+// ipResponse is response of /ip.json
+type ipResponse struct {
+	IP         string   `json:"ip"`
+	RemoteAddr string   `json:"remoteAddr"`
+	Forwarded  []string `json:"forwarded,omitempty"`
+	Geo        *geoInfo `json:"geo,omitempty"`
+	ASN        *asnInfo `json:"asn,omitempty"`
+}
+
+func ipJSONHandler(w http.ResponseWriter, r *http.Request) {
+	ip, hops := clientIP(r)
+	resp := ipResponse{
+		IP:         ip,
+		RemoteAddr: r.RemoteAddr,
+		Forwarded:  hops,
+	}
+	resp.Geo, resp.ASN = lookupIP(ip)
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
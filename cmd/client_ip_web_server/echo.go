@@ -0,0 +1,87 @@
+package client_ip_web_server
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+)
+
+// maxEchoBody is maximum size of request body returned by /echo
+const maxEchoBody = 1 << 20
+
+type echoResponse struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Proto         string      `json:"proto"`
+	Host          string      `json:"host"`
+	RemoteAddr    string      `json:"remoteAddr"`
+	ClientIP      string      `json:"clientIp"`
+	Headers       http.Header `json:"headers"`
+	Query         url.Values  `json:"query"`
+	TLS           *echoTLS    `json:"tls,omitempty"`
+	Body          string      `json:"body,omitempty"`
+	BodyBase64    string      `json:"bodyBase64,omitempty"`
+	BodyTruncated bool        `json:"bodyTruncated,omitempty"`
+}
+
+type echoTLS struct {
+	Version            string   `json:"version"`
+	CipherSuite        string   `json:"cipherSuite"`
+	ServerName         string   `json:"serverName,omitempty"`
+	NegotiatedProtocol string   `json:"negotiatedProtocol,omitempty"`
+	Resumed            bool     `json:"resumed"`
+	PeerCertificates   []string `json:"peerCertificates,omitempty"`
+}
+
+// echoHandler returns the request as JSON, binary body is base64 encoded
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	ip, _ := clientIP(r)
+	resp := echoResponse{
+		Method:     r.Method,
+		URL:        r.RequestURI,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		ClientIP:   ip,
+		Headers:    r.Header,
+		Query:      r.URL.Query(),
+		TLS:        tlsInfo(r.TLS),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEchoBody+1))
+	if err != nil {
+		http.Error(w, "Error reading body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxEchoBody {
+		body = body[:maxEchoBody]
+		resp.BodyTruncated = true
+	}
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	writeJSON(w, resp)
+}
+
+func tlsInfo(state *tls.ConnectionState) *echoTLS {
+	if state == nil {
+		return nil
+	}
+	info := &echoTLS{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		Resumed:            state.DidResume,
+	}
+	for _, cert := range state.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, cert.Subject.String())
+	}
+	return info
+}
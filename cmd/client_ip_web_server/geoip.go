@@ -0,0 +1,104 @@
+package client_ip_web_server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+var geoDB *geoip2.Reader
+var asnDB *geoip2.Reader
+
+type geoInfo struct {
+	Country     string  `json:"country,omitempty"`
+	CountryName string  `json:"countryName,omitempty"`
+	Continent   string  `json:"continent,omitempty"`
+	City        string  `json:"city,omitempty"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+	TimeZone    string  `json:"timeZone,omitempty"`
+}
+
+type asnInfo struct {
+	Number       uint   `json:"number"`
+	Organization string `json:"organization"`
+}
+
+// openGeoIP opens optional mmdb files, they are kept open for the
+// lifetime of the server
+func openGeoIP(geoPath, asnPath string) error {
+	var err error
+	if geoPath != "" {
+		geoDB, err = geoip2.Open(geoPath)
+		if err != nil {
+			return fmt.Errorf("--geoip-db: %w", err)
+		}
+	}
+	if asnPath != "" {
+		asnDB, err = geoip2.Open(asnPath)
+		if err != nil {
+			return fmt.Errorf("--asn-db: %w", err)
+		}
+	}
+	return nil
+}
+
+// lookupIP returns nil for IPs not found in databases (private ranges)
+func lookupIP(s string) (*geoInfo, *asnInfo) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, nil
+	}
+
+	var geo *geoInfo
+	if geoDB != nil {
+		geo = lookupGeo(ip)
+	}
+	var asn *asnInfo
+	if asnDB != nil {
+		if record, err := asnDB.ASN(ip); err == nil && record.AutonomousSystemNumber != 0 {
+			asn = &asnInfo{
+				Number:       record.AutonomousSystemNumber,
+				Organization: record.AutonomousSystemOrganization,
+			}
+		}
+	}
+	return geo, asn
+}
+
+// lookupGeo uses City lookup for City databases and Country lookup for
+// Country databases
+func lookupGeo(ip net.IP) *geoInfo {
+	var geo geoInfo
+	if strings.Contains(geoDB.Metadata().DatabaseType, "City") {
+		record, err := geoDB.City(ip)
+		if err != nil {
+			return nil
+		}
+		geo = geoInfo{
+			Country:     record.Country.IsoCode,
+			CountryName: record.Country.Names["en"],
+			Continent:   record.Continent.Code,
+			City:        record.City.Names["en"],
+			Latitude:    record.Location.Latitude,
+			Longitude:   record.Location.Longitude,
+			TimeZone:    record.Location.TimeZone,
+		}
+	} else {
+		record, err := geoDB.Country(ip)
+		if err != nil {
+			return nil
+		}
+		geo = geoInfo{
+			Country:     record.Country.IsoCode,
+			CountryName: record.Country.Names["en"],
+			Continent:   record.Continent.Code,
+		}
+	}
+	if geo == (geoInfo{}) {
+		return nil
+	}
+	return &geo
+}
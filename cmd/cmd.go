@@ -9,6 +9,7 @@ import (
 	_ "github.com/sikalabs/slr/cmd/azure"
 	_ "github.com/sikalabs/slr/cmd/azure/subscription_cleanup"
 	_ "github.com/sikalabs/slr/cmd/break_line"
+	_ "github.com/sikalabs/slr/cmd/cert_check"
 	_ "github.com/sikalabs/slr/cmd/check_docker_credentials"
 	_ "github.com/sikalabs/slr/cmd/client_ip_web_server"
 	_ "github.com/sikalabs/slr/cmd/copy_from_cloud"
@@ -18,6 +19,7 @@ import (
 	_ "github.com/sikalabs/slr/cmd/download_file_from_gitlab"
 	_ "github.com/sikalabs/slr/cmd/du_gitlab_tls_update"
 	_ "github.com/sikalabs/slr/cmd/example"
+	_ "github.com/sikalabs/slr/cmd/exporter"
 	_ "github.com/sikalabs/slr/cmd/get_gps_info_from_jpg"
 	_ "github.com/sikalabs/slr/cmd/get_helm_chart_version_from_repo"
 	_ "github.com/sikalabs/slr/cmd/get_jwt_from_oidc"
@@ -34,12 +36,18 @@ import (
 	_ "github.com/sikalabs/slr/cmd/gojekyll"
 	_ "github.com/sikalabs/slr/cmd/install_du_gitlab_tls_update"
 	_ "github.com/sikalabs/slr/cmd/install_restart_eno1_systemd"
+	_ "github.com/sikalabs/slr/cmd/install_tls_sync"
+	_ "github.com/sikalabs/slr/cmd/k8s_event_alerts"
+	_ "github.com/sikalabs/slr/cmd/k8s_secret_get"
+	_ "github.com/sikalabs/slr/cmd/k8s_usage"
 	_ "github.com/sikalabs/slr/cmd/kubeconfig_from_vault"
+	_ "github.com/sikalabs/slr/cmd/kubeconfig_to_vault"
 	_ "github.com/sikalabs/slr/cmd/kubernetes_homepage"
 	_ "github.com/sikalabs/slr/cmd/kubernetes_oidc_login"
 	_ "github.com/sikalabs/slr/cmd/list_mimio_s3_bucket"
 	_ "github.com/sikalabs/slr/cmd/list_mimio_s3_buckets"
 	_ "github.com/sikalabs/slr/cmd/memory_usage"
+	_ "github.com/sikalabs/slr/cmd/net_watchdog"
 	_ "github.com/sikalabs/slr/cmd/next_dev_docker_tag"
 	_ "github.com/sikalabs/slr/cmd/nothing"
 	_ "github.com/sikalabs/slr/cmd/ondrejsika"
@@ -75,10 +83,14 @@ import (
 	_ "github.com/sikalabs/slr/cmd/suffix"
 	_ "github.com/sikalabs/slr/cmd/test_clisso_cli"
 	_ "github.com/sikalabs/slr/cmd/time_exporter"
+	_ "github.com/sikalabs/slr/cmd/tls_sync"
 	"github.com/sikalabs/slr/cmd/training"
 	_ "github.com/sikalabs/slr/cmd/training/az_training_user_creds"
 	_ "github.com/sikalabs/slr/cmd/training/kubernetes"
 	_ "github.com/sikalabs/slr/cmd/training/kubernetes/connect"
+	_ "github.com/sikalabs/slr/cmd/training/kubernetes/students"
+	_ "github.com/sikalabs/slr/cmd/training/kubernetes/students/create"
+	_ "github.com/sikalabs/slr/cmd/training/kubernetes/students/revoke"
 	_ "github.com/sikalabs/slr/cmd/training/load_kubeconfig"
 	_ "github.com/sikalabs/slr/cmd/training/openshift_creds"
 	_ "github.com/sikalabs/slr/cmd/training/openshift_pull_secret"
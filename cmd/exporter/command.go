@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func (p *CommandCollector) probe(ctx context.Context) ([]sample, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Run)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+	samples := []sample{{name: "exit_code", help: "Exit code of the command (-1 if it didn't run)", value: float64(exitCode)}}
+	if err != nil {
+		return samples, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	values, err := parseCommandOutput(string(out))
+	if err != nil {
+		return samples, err
+	}
+	return append(samples, values...), nil
+}
+
+// parseCommandOutput parses a single number or lines "<key> <number>",
+// keys must be unique UTF-8 strings (values of the key label)
+func parseCommandOutput(out string) ([]sample, error) {
+	const help = "Value from output of the command"
+	out = strings.TrimSpace(out)
+	if value, err := strconv.ParseFloat(out, 64); err == nil {
+		return []sample{{help: help, value: value}}, nil
+	}
+
+	samples := []sample{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid output line %q, expected a number or \"<key> <number>\"", line)
+		}
+		key := fields[0]
+		if !utf8.ValidString(key) {
+			return nil, fmt.Errorf("invalid key in output line %q, must be UTF-8", line)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %q in output", key)
+		}
+		seen[key] = true
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in output line %q", line)
+		}
+		samples = append(samples, sample{help: help, value: value, labels: map[string]string{"key": key}})
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("command has no output")
+	}
+	return samples, nil
+}
@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/sikalabs/slr/internal/env_utils"
+	"github.com/sikalabs/slr/internal/time_utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Example config (${VAR} is expanded from environment), metrics of each
+// collector are prefixed by its Name:
+//
+//	Interval: 1m
+//	Labels:
+//	  host: backup-1
+//	Collectors:
+//	  - Name: postgres_connections
+//	    Command:
+//	      Run: psql -tAc "select count(*) from pg_stat_activity"
+//	  - Name: homepage
+//	    Interval: 15s
+//	    HTTP:
+//	      URL: https://example.com
+//	  - Name: postgres_port
+//	    TCP:
+//	      Address: db.example.com:5432
+//	  - Name: homepage_cert
+//	    Interval: 1h
+//	    TLS:
+//	      Address: example.com:443
+//	  - Name: dump
+//	    File:
+//	      Path: /backup/dump-*.sql.gz
+//	  - Name: s3_backups
+//	    Interval: 10m
+//	    Labels:
+//	      bucket: backups
+//	    S3:
+//	      Bucket: backups
+//	      Prefix: postgres/
+//	      Endpoint: https://minio.example.com
+//	      AccessKey: ${S3_ACCESS_KEY}
+//	      SecretKey: ${S3_SECRET_KEY}
+type Config struct {
+	// Default interval and timeout of collectors
+	Interval string `yaml:"Interval"`
+	Timeout  string `yaml:"Timeout"`
+	// Labels added to metrics of all collectors
+	Labels     map[string]string `yaml:"Labels"`
+	Collectors []Collector       `yaml:"Collectors"`
+}
+
+// Collector has exactly one of Command, HTTP, TCP, TLS, File or S3 set
+type Collector struct {
+	Name     string            `yaml:"Name"`
+	Interval string            `yaml:"Interval"`
+	Timeout  string            `yaml:"Timeout"`
+	Labels   map[string]string `yaml:"Labels"`
+
+	Command *CommandCollector `yaml:"Command"`
+	HTTP    *HTTPCollector    `yaml:"HTTP"`
+	TCP     *TCPCollector     `yaml:"TCP"`
+	TLS     *TLSCollector     `yaml:"TLS"`
+	File    *FileCollector    `yaml:"File"`
+	S3      *S3Collector      `yaml:"S3"`
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// CommandCollector runs Run with sh -c, output is a single number
+// (<name>) or lines "<key> <number>" (<name>{key="<key>"})
+type CommandCollector struct {
+	Run string `yaml:"Run"`
+}
+
+// HTTPCollector fails if status is not in ExpectedStatus (default 2xx)
+type HTTPCollector struct {
+	URL                string            `yaml:"URL"`
+	Method             string            `yaml:"Method"`
+	Headers            map[string]string `yaml:"Headers"`
+	ExpectedStatus     []int             `yaml:"ExpectedStatus"`
+	InsecureSkipVerify bool              `yaml:"InsecureSkipVerify"`
+}
+
+type TCPCollector struct {
+	Address string `yaml:"Address"`
+}
+
+// TLSCollector reads the certificate even if it's not trusted, trust is
+// exposed as <name>_cert_verified
+type TLSCollector struct {
+	Address    string `yaml:"Address"`
+	ServerName string `yaml:"ServerName"`
+}
+
+// FileCollector Path can be a glob, the newest match is used
+type FileCollector struct {
+	Path string `yaml:"Path"`
+}
+
+// S3Collector credentials default to the AWS credential chain
+type S3Collector struct {
+	Bucket    string `yaml:"Bucket"`
+	Prefix    string `yaml:"Prefix"`
+	Endpoint  string `yaml:"Endpoint"`
+	Region    string `yaml:"Region"`
+	AccessKey string `yaml:"AccessKey"`
+	SecretKey string `yaml:"SecretKey"`
+}
+
+const (
+	defaultInterval = time.Minute
+	defaultTimeout  = 10 * time.Second
+)
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func loadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal([]byte(env_utils.Expand(string(data))), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if len(config.Collectors) == 0 {
+		return nil, fmt.Errorf("config has no Collectors")
+	}
+	interval, err := parseDuration(config.Interval, defaultInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Interval: %w", err)
+	}
+	timeout, err := parseDuration(config.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Timeout: %w", err)
+	}
+	if err := validateLabels(config.Labels); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for i := range config.Collectors {
+		c := &config.Collectors[i]
+		if !metricNameRegexp.MatchString(c.Name) {
+			return nil, fmt.Errorf("collector #%d: invalid Name %q (a-z, A-Z, 0-9 and _)", i, c.Name)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("collector %s: duplicate Name", c.Name)
+		}
+		names[c.Name] = true
+
+		c.interval, err = parseDuration(c.Interval, interval)
+		if err != nil {
+			return nil, fmt.Errorf("collector %s: invalid Interval: %w", c.Name, err)
+		}
+		c.timeout, err = parseDuration(c.Timeout, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("collector %s: invalid Timeout: %w", c.Name, err)
+		}
+		if err := validateLabels(c.Labels); err != nil {
+			return nil, fmt.Errorf("collector %s: %w", c.Name, err)
+		}
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("collector %s: %w", c.Name, err)
+		}
+	}
+
+	return &config, nil
+}
+
+func parseDuration(s string, defaultValue time.Duration) (time.Duration, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	d, err := time_utils.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !metricNameRegexp.MatchString(name) || name == "collector" {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+func (c *Collector) validate() error {
+	set := 0
+	for _, isSet := range []bool{c.Command != nil, c.HTTP != nil, c.TCP != nil, c.TLS != nil, c.File != nil, c.S3 != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of Command, HTTP, TCP, TLS, File or S3 is required")
+	}
+
+	switch {
+	case c.Command != nil && c.Command.Run == "":
+		return fmt.Errorf("Command.Run is required")
+	case c.HTTP != nil && c.HTTP.URL == "":
+		return fmt.Errorf("HTTP.URL is required")
+	case c.TCP != nil && c.TCP.Address == "":
+		return fmt.Errorf("TCP.Address is required")
+	case c.TLS != nil && c.TLS.Address == "":
+		return fmt.Errorf("TLS.Address is required")
+	case c.File != nil && c.File.Path == "":
+		return fmt.Errorf("File.Path is required")
+	case c.S3 != nil && c.S3.Bucket == "":
+		return fmt.Errorf("S3.Bucket is required")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/httpserver"
+	"github.com/spf13/cobra"
+)
+
+var FlagConfig string
+var FlagServer *httpserver.Options
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{
+		Listen:       ":8000",
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	})
+	Cmd.Flags().StringVarP(&FlagConfig, "config", "c", "", "Path to YAML config with collectors (required)")
+	Cmd.MarkFlagRequired("config")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Prometheus exporter with collectors defined in YAML",
+	Long: `Prometheus exporter with collectors defined in YAML.
+
+Collectors run in the background on their own interval, /metrics returns
+results of the last run. Metrics of each collector are prefixed by its
+Name:
+
+  Command  <name> (or <name>{key} for "<key> <value>" lines), <name>_exit_code
+  HTTP     <name>_status_code, <name>_duration_seconds
+  TCP      <name>_duration_seconds
+  TLS      <name>_cert_expiry_days, <name>_cert_not_after_timestamp_seconds,
+           <name>_cert_verified, <name>_duration_seconds
+  File     <name>_age_seconds, <name>_size_bytes, <name>_files
+  S3       <name>_newest_object_age_seconds, <name>_newest_object_timestamp_seconds,
+           <name>_objects, <name>_size_bytes
+
+All collectors expose slr_exporter_collector_success,
+slr_exporter_collector_duration_seconds and
+slr_exporter_collector_last_run_timestamp_seconds with label collector.`,
+	Example: `  slr exporter --config exporter.yml --listen :9100`,
+	Args:    cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		config, err := loadConfig(FlagConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := exporter(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func exporter(config *Config) error {
+	runners := []*runner{}
+	for _, c := range config.Collectors {
+		r, err := newRunner(c, config.Labels)
+		if err != nil {
+			return fmt.Errorf("collector %s: %w", c.Name, err)
+		}
+		runners = append(runners, r)
+	}
+	if err := prometheus.Register(&metricsCollector{runners: runners}); err != nil {
+		return err
+	}
+	for _, r := range runners {
+		go r.run()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	fmt.Println("Metrics on " + FlagServer.URL() + "/metrics")
+	return FlagServer.ListenAndServe(mux)
+}
@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (p *FileCollector) probe(ctx context.Context) ([]sample, error) {
+	paths, err := filepath.Glob(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var newest os.FileInfo
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+		if newest == nil || stat.ModTime().After(newest.ModTime()) {
+			newest = stat
+		}
+	}
+	if newest == nil {
+		return []sample{{name: "files", help: "Number of files matching the path", value: 0}}, fmt.Errorf("no file matches %s", p.Path)
+	}
+	return []sample{
+		{name: "files", help: "Number of files matching the path", value: float64(len(paths))},
+		{name: "age_seconds", help: "Age of the newest matching file by modification time", value: time.Since(newest.ModTime()).Seconds()},
+		{name: "size_bytes", help: "Size of the newest matching file", value: float64(newest.Size())},
+	}, nil
+}
@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+func (p *HTTPCollector) probe(ctx context.Context) ([]sample, error) {
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify},
+	}}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+
+	samples := []sample{
+		{name: "status_code", help: "HTTP status code of the response", value: float64(resp.StatusCode)},
+		{name: "duration_seconds", help: "Duration of the HTTP request including the body", value: time.Since(start).Seconds()},
+	}
+	expected := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if len(p.ExpectedStatus) > 0 {
+		expected = slices.Contains(p.ExpectedStatus, resp.StatusCode)
+	}
+	if !expected {
+		return samples, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return samples, nil
+}
+
+func (p *TCPCollector) probe(ctx context.Context) ([]sample, error) {
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return []sample{
+		{name: "duration_seconds", help: "Duration of the TCP connect", value: time.Since(start).Seconds()},
+	}, nil
+}
+
+func (p *TLSCollector) probe(ctx context.Context) ([]sample, error) {
+	serverName := p.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(p.Address)
+		if err != nil {
+			return nil, err
+		}
+		serverName = host
+	}
+	// certificate is verified below, expiry of untrusted certificates is
+	// exposed too
+	dialer := tls.Dialer{Config: &tls.Config{ServerName: serverName, InsecureSkipVerify: true}}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Since(start)
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	conn.Close()
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("server sent no certificate")
+	}
+
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, verifyErr := leaf.Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+
+	return []sample{
+		{name: "cert_expiry_days", help: "Days to expiry of the certificate (negative if expired)", value: time.Until(leaf.NotAfter).Hours() / 24},
+		{name: "cert_not_after_timestamp_seconds", help: "Unix timestamp of NotAfter of the certificate", value: float64(leaf.NotAfter.Unix())},
+		{name: "cert_verified", help: "1 if the certificate chain is trusted and valid for the server name", value: boolToFloat(verifyErr == nil)},
+		{name: "duration_seconds", help: "Duration of the TCP connect and TLS handshake", value: duration.Seconds()},
+	}, nil
+}
@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sample is one value produced by a collector, its metric name is
+// <collector name>_<name> (or just the collector name for empty name)
+type sample struct {
+	name   string
+	help   string
+	value  float64
+	labels map[string]string
+}
+
+type probe interface {
+	probe(ctx context.Context) ([]sample, error)
+}
+
+// runner runs one collector on its interval and keeps its last result
+type runner struct {
+	config Collector
+	labels map[string]string
+	probe  probe
+
+	mu       sync.Mutex
+	samples  []sample
+	success  bool
+	duration time.Duration
+	lastRun  time.Time
+}
+
+func newRunner(c Collector, globalLabels map[string]string) (*runner, error) {
+	labels := maps.Clone(globalLabels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, c.Labels)
+
+	r := &runner{config: c, labels: labels}
+	switch {
+	case c.Command != nil:
+		r.probe = c.Command
+	case c.HTTP != nil:
+		r.probe = c.HTTP
+	case c.TCP != nil:
+		r.probe = c.TCP
+	case c.TLS != nil:
+		r.probe = c.TLS
+	case c.File != nil:
+		r.probe = c.File
+	case c.S3 != nil:
+		p, err := newS3Probe(c.S3)
+		if err != nil {
+			return nil, err
+		}
+		r.probe = p
+	}
+	return r, nil
+}
+
+func (r *runner) run() {
+	ticker := time.NewTicker(r.config.interval)
+	defer ticker.Stop()
+	for {
+		r.runOnce()
+		<-ticker.C
+	}
+}
+
+func (r *runner) runOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.timeout)
+	defer cancel()
+
+	start := time.Now()
+	samples, err := r.probe.probe(ctx)
+	duration := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: collector %s: %v\n", r.config.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = samples
+	r.success = err == nil
+	r.duration = duration
+	r.lastRun = start
+}
+
+var (
+	successDesc = prometheus.NewDesc(
+		"slr_exporter_collector_success",
+		"1 if the last run of the collector succeeded",
+		[]string{"collector"}, nil)
+	durationDesc = prometheus.NewDesc(
+		"slr_exporter_collector_duration_seconds",
+		"Duration of the last run of the collector",
+		[]string{"collector"}, nil)
+	lastRunDesc = prometheus.NewDesc(
+		"slr_exporter_collector_last_run_timestamp_seconds",
+		"Unix timestamp of the last run of the collector",
+		[]string{"collector"}, nil)
+)
+
+// metricsCollector exposes last results of all runners, it's unchecked
+// (describes nothing) as metrics are known only after the first run
+type metricsCollector struct {
+	runners []*runner
+}
+
+func (m *metricsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (m *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range m.runners {
+		r.mu.Lock()
+		if !r.lastRun.IsZero() {
+			name := r.config.Name
+			ch <- prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, boolToFloat(r.success), name)
+			ch <- prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, r.duration.Seconds(), name)
+			ch <- prometheus.MustNewConstMetric(lastRunDesc, prometheus.GaugeValue, float64(r.lastRun.Unix()), name)
+		}
+		for _, s := range r.samples {
+			ch <- r.metric(s)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *runner) metric(s sample) prometheus.Metric {
+	name := r.config.Name
+	if s.name != "" {
+		name += "_" + s.name
+	}
+	labels := maps.Clone(r.labels)
+	maps.Copy(labels, s.labels)
+	names := slices.Sorted(maps.Keys(labels))
+	values := make([]string, 0, len(names))
+	for _, n := range names {
+		values = append(values, labels[n])
+	}
+	desc := prometheus.NewDesc(name, s.help, names, nil)
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.value, values...)
+	if err != nil {
+		// reported by the registry as scrape error instead of panic
+		return prometheus.NewInvalidMetric(desc, err)
+	}
+	return metric
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
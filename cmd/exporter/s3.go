@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type s3Probe struct {
+	config *S3Collector
+	client *s3.Client
+}
+
+func newS3Probe(c *S3Collector) (*s3Probe, error) {
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if c.AccessKey != "" && c.SecretKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if c.Endpoint != "" {
+			o.BaseEndpoint = aws.String(c.Endpoint)
+			// MinIO requires path-style addressing
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Probe{config: c, client: client}, nil
+}
+
+// probe lists all objects of the prefix to find the newest one
+func (p *s3Probe) probe(ctx context.Context) ([]sample, error) {
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.config.Bucket),
+		Prefix: aws.String(p.config.Prefix),
+	})
+
+	var count, size int64
+	var newest time.Time
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			count++
+			size += aws.ToInt64(obj.Size)
+			if obj.LastModified != nil && obj.LastModified.After(newest) {
+				newest = *obj.LastModified
+			}
+		}
+	}
+
+	samples := []sample{
+		{name: "objects", help: "Number of objects with the prefix", value: float64(count)},
+		{name: "size_bytes", help: "Total size of objects with the prefix", value: float64(size)},
+	}
+	if count == 0 {
+		return samples, fmt.Errorf("no objects in s3://%s/%s", p.config.Bucket, p.config.Prefix)
+	}
+	return append(samples,
+		sample{name: "newest_object_age_seconds", help: "Age of the newest object with the prefix", value: time.Since(newest).Seconds()},
+		sample{name: "newest_object_timestamp_seconds", help: "Unix timestamp of LastModified of the newest object with the prefix", value: float64(newest.Unix())},
+	), nil
+}
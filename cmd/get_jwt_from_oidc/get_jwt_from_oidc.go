@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/coreos/go-oidc"
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/httpserver"
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
 )
@@ -15,6 +17,7 @@ import (
 var FlagIssuer string
 var FlagClientID string
 var FlagClientSecret string
+var FlagServer *httpserver.Options
 
 var Cmd = &cobra.Command{
 	Use:   "get-jwt-from-oidc",
@@ -47,6 +50,11 @@ func init() {
 		"Client Secret",
 	)
 	Cmd.MarkFlagRequired("client-secret")
+	FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{
+		Listen:       "localhost:8000",
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	})
 }
 
 func Server(issuer, clientID, clientSecret string) {
@@ -61,15 +69,16 @@ func Server(issuer, clientID, clientSecret string) {
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Endpoint:     provider.Endpoint(),
-		RedirectURL:  "http://localhost:8000/callback",
+		RedirectURL:  FlagServer.URL() + "/callback",
 		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, config.AuthCodeURL("state"), http.StatusFound)
 	})
 
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Query().Get("state") != "state" {
 			http.Error(w, "state did not match", http.StatusBadRequest)
 			return
@@ -131,6 +140,8 @@ func Server(issuer, clientID, clientSecret string) {
 		_ = refreshToken // Refresh Token is now verified and can be used
 	})
 
-	fmt.Println("http://localhost:8000/")
-	log.Fatal(http.ListenAndServe(":8000", nil))
+	fmt.Println(FlagServer.URL() + "/")
+	if err := FlagServer.ListenAndServe(mux); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -3,35 +3,264 @@ package get_nodes_from_kubernetes
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/k8s"
 	"github.com/spf13/cobra"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
+var FlagOutput string
+var FlagSelector string
+var FlagK8s *k8s.Options
+
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", OutputTable, "Output format ("+strings.Join(Outputs, ", ")+")")
+	Cmd.Flags().StringVarP(&FlagSelector, "selector", "l", "", "Node label selector (e.g. node-role.kubernetes.io/control-plane)")
+	FlagK8s = k8s.AddClusterFlags(Cmd)
 }
 
 var Cmd = &cobra.Command{
-	Use:  "get-nodes-from-kubernetes",
-	Args: cobra.NoArgs,
+	Use:   "get-nodes-from-kubernetes",
+	Short: "Print inventory of Kubernetes nodes (versions, IPs, capacity, taints, conditions)",
+	Args:  cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
-		getNodesFromKubernetes()
+		err := getNodesFromKubernetes(FlagK8s, FlagSelector, FlagOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	},
 }
 
-func getNodesFromKubernetes() {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-	config, _ := kubeConfig.ClientConfig()
-	clientset, _ := kubernetes.NewForConfig(config)
-	nodesList, _ := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
-	for _, node := range nodesList.Items {
-		fmt.Println(node.Name)
+// Node is one row of the inventory, CPU is in millicores and memory in
+// bytes
+type Node struct {
+	Name              string    `json:"name"`
+	Status            string    `json:"status"`
+	Roles             []string  `json:"roles"`
+	Created           time.Time `json:"created"`
+	Age               string    `json:"age"`
+	KubeletVersion    string    `json:"kubeletVersion"`
+	OSImage           string    `json:"osImage"`
+	KernelVersion     string    `json:"kernelVersion"`
+	ContainerRuntime  string    `json:"containerRuntime"`
+	InternalIPs       []string  `json:"internalIPs"`
+	ExternalIPs       []string  `json:"externalIPs"`
+	CPUAllocatable    int64     `json:"cpuAllocatableMillicores"`
+	CPURequested      int64     `json:"cpuRequestedMillicores"`
+	MemoryAllocatable int64     `json:"memoryAllocatableBytes"`
+	MemoryRequested   int64     `json:"memoryRequestedBytes"`
+	PodsAllocatable   int64     `json:"podsAllocatable"`
+	Pods              int       `json:"pods"`
+	Taints            []string  `json:"taints"`
+	Conditions        []string  `json:"conditions"`
+}
+
+func getNodesFromKubernetes(k8sOptions *k8s.Options, selector, output string) error {
+	format, ok := formatters[output]
+	if !ok {
+		return fmt.Errorf("unknown output %q, supported outputs: %s", output, strings.Join(Outputs, ", "))
+	}
+
+	clientset, err := k8sOptions.Clientset()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := getNodes(context.TODO(), clientset, selector, time.Now())
+	if err != nil {
+		return err
+	}
+	return format(os.Stdout, nodes)
+}
+
+func getNodes(ctx context.Context, clientset kubernetes.Interface, selector string, now time.Time) ([]Node, error) {
+	nodeList, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	// Requests of pods which occupy node resources, the same the scheduler
+	// counts (finished pods are not)
+	podList, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	requests := map[string]corev1.ResourceList{}
+	pods := map[string]int{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		pods[pod.Spec.NodeName]++
+		addResourceList(requests, pod.Spec.NodeName, podRequests(pod))
+	}
+
+	nodes := make([]Node, 0, len(nodeList.Items))
+	for i := range nodeList.Items {
+		n := &nodeList.Items[i]
+		node := Node{
+			Name:              n.Name,
+			Status:            nodeStatus(n),
+			Roles:             nodeRoles(n),
+			Created:           n.CreationTimestamp.Time,
+			Age:               humanDuration(now.Sub(n.CreationTimestamp.Time)),
+			KubeletVersion:    n.Status.NodeInfo.KubeletVersion,
+			OSImage:           n.Status.NodeInfo.OSImage,
+			KernelVersion:     n.Status.NodeInfo.KernelVersion,
+			ContainerRuntime:  n.Status.NodeInfo.ContainerRuntimeVersion,
+			InternalIPs:       nodeAddresses(n, corev1.NodeInternalIP),
+			ExternalIPs:       nodeAddresses(n, corev1.NodeExternalIP),
+			CPUAllocatable:    n.Status.Allocatable.Cpu().MilliValue(),
+			MemoryAllocatable: n.Status.Allocatable.Memory().Value(),
+			PodsAllocatable:   n.Status.Allocatable.Pods().Value(),
+			Pods:              pods[n.Name],
+			Taints:            []string{},
+			Conditions:        []string{},
+		}
+		if r, ok := requests[n.Name]; ok {
+			node.CPURequested = r.Cpu().MilliValue()
+			node.MemoryRequested = r.Memory().Value()
+		}
+		for _, t := range n.Spec.Taints {
+			node.Taints = append(node.Taints, taintString(t))
+		}
+		for _, c := range n.Status.Conditions {
+			node.Conditions = append(node.Conditions, fmt.Sprintf("%s=%s", c.Type, c.Status))
+		}
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// podRequests returns effective requests of the pod: sum of containers or
+// the biggest init container (whichever is bigger) plus pod overhead
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(reqs, c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		// sidecar (restartable init container) runs the whole pod life
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			addResources(reqs, c.Resources.Requests)
+			continue
+		}
+		for name, q := range c.Resources.Requests {
+			if current, ok := reqs[name]; !ok || q.Cmp(current) > 0 {
+				reqs[name] = q.DeepCopy()
+			}
+		}
+	}
+	addResources(reqs, pod.Spec.Overhead)
+	return reqs
+}
+
+func addResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		current := dst[name]
+		current.Add(q)
+		dst[name] = current
+	}
+}
+
+func addResourceList(m map[string]corev1.ResourceList, key string, src corev1.ResourceList) {
+	if _, ok := m[key]; !ok {
+		m[key] = corev1.ResourceList{}
+	}
+	addResources(m[key], src)
+}
+
+// nodeStatus returns status like kubectl get nodes (Ready,
+// NotReady,SchedulingDisabled, ...) with pressure conditions appended
+func nodeStatus(n *corev1.Node) string {
+	status := []string{"Unknown"}
+	for _, c := range n.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			if c.Status == corev1.ConditionTrue {
+				status[0] = "Ready"
+			} else {
+				status[0] = "NotReady"
+			}
+		} else if c.Status == corev1.ConditionTrue {
+			status = append(status, string(c.Type))
+		}
+	}
+	if n.Spec.Unschedulable {
+		status = append(status, "SchedulingDisabled")
+	}
+	return strings.Join(status, ",")
+}
+
+func nodeRoles(n *corev1.Node) []string {
+	roles := []string{}
+	for label, value := range n.Labels {
+		if role, ok := strings.CutPrefix(label, "node-role.kubernetes.io/"); ok && role != "" {
+			roles = append(roles, role)
+		} else if label == "kubernetes.io/role" && value != "" {
+			roles = append(roles, value)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func nodeAddresses(n *corev1.Node, addressType corev1.NodeAddressType) []string {
+	addresses := []string{}
+	for _, a := range n.Status.Addresses {
+		if a.Type == addressType {
+			addresses = append(addresses, a.Address)
+		}
+	}
+	return addresses
+}
+
+func taintString(t corev1.Taint) string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+func formatMemory(bytes int64) string {
+	return fmt.Sprintf("%.1fGi", float64(bytes)/(1<<30))
+}
+
+func formatCPU(millicores int64) string {
+	if millicores%1000 == 0 {
+		return fmt.Sprintf("%d", millicores/1000)
+	}
+	return fmt.Sprintf("%dm", millicores)
+}
+
+func percent(part, total int64) int64 {
+	if total == 0 {
+		return 0
 	}
+	return part * 100 / total
 }
@@ -0,0 +1,122 @@
+package get_nodes_from_kubernetes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	OutputTable    = "table"
+	OutputJSON     = "json"
+	OutputCSV      = "csv"
+	OutputMarkdown = "markdown"
+)
+
+var Outputs = []string{OutputTable, OutputJSON, OutputCSV, OutputMarkdown}
+
+var formatters = map[string]func(io.Writer, []Node) error{
+	OutputTable:    writeTable,
+	OutputJSON:     writeJSON,
+	OutputCSV:      writeCSV,
+	OutputMarkdown: writeMarkdown,
+	"md":           writeMarkdown,
+}
+
+func join(values []string) string {
+	if len(values) == 0 {
+		return "<none>"
+	}
+	return strings.Join(values, ",")
+}
+
+func cpuUsage(n Node) string {
+	return fmt.Sprintf("%s/%s (%d%%)", formatCPU(n.CPURequested), formatCPU(n.CPUAllocatable), percent(n.CPURequested, n.CPUAllocatable))
+}
+
+func memoryUsage(n Node) string {
+	return fmt.Sprintf("%s/%s (%d%%)", formatMemory(n.MemoryRequested), formatMemory(n.MemoryAllocatable), percent(n.MemoryRequested, n.MemoryAllocatable))
+}
+
+func writeTable(w io.Writer, nodes []Node) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tROLES\tAGE\tVERSION\tINTERNAL-IP\tEXTERNAL-IP\tOS-IMAGE\tKERNEL\tRUNTIME\tCPU REQ/ALLOC\tMEMORY REQ/ALLOC\tPODS\tTAINTS")
+	for _, n := range nodes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+			n.Name, n.Status, join(n.Roles), n.Age, n.KubeletVersion,
+			join(n.InternalIPs), join(n.ExternalIPs), n.OSImage, n.KernelVersion, n.ContainerRuntime,
+			cpuUsage(n), memoryUsage(n), n.Pods, n.PodsAllocatable, join(n.Taints),
+		)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, nodes []Node) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(nodes)
+}
+
+// writeCSV writes raw numbers (millicores, bytes) so the output can be
+// processed in a spreadsheet, lists are separated by space
+func writeCSV(w io.Writer, nodes []Node) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"name", "status", "roles", "created", "age", "kubelet_version", "os_image", "kernel_version",
+		"container_runtime", "internal_ips", "external_ips",
+		"cpu_allocatable_millicores", "cpu_requested_millicores",
+		"memory_allocatable_bytes", "memory_requested_bytes",
+		"pods_allocatable", "pods", "taints", "conditions",
+	})
+	for _, n := range nodes {
+		cw.Write([]string{
+			n.Name, n.Status, strings.Join(n.Roles, " "), n.Created.UTC().Format("2006-01-02T15:04:05Z"), n.Age,
+			n.KubeletVersion, n.OSImage, n.KernelVersion, n.ContainerRuntime,
+			strings.Join(n.InternalIPs, " "), strings.Join(n.ExternalIPs, " "),
+			strconv.FormatInt(n.CPUAllocatable, 10), strconv.FormatInt(n.CPURequested, 10),
+			strconv.FormatInt(n.MemoryAllocatable, 10), strconv.FormatInt(n.MemoryRequested, 10),
+			strconv.FormatInt(n.PodsAllocatable, 10), strconv.Itoa(n.Pods),
+			strings.Join(n.Taints, " "), strings.Join(n.Conditions, " "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMarkdown writes table in the same style as servers-md
+func writeMarkdown(w io.Writer, nodes []Node) error {
+	var md strings.Builder
+	md.WriteString("| Name | Status | Roles | Age | Version | Internal IP | External IP | OS | Kernel | Runtime | CPU | Memory | Pods | Taints |\n")
+	md.WriteString("|------|--------|-------|-----|---------|-------------|-------------|----|--------|---------|-----|--------|------|--------|\n")
+	for _, n := range nodes {
+		md.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %d/%d | %s |\n",
+			n.Name, n.Status, mdList(n.Roles), n.Age, n.KubeletVersion,
+			mdList(n.InternalIPs), mdList(n.ExternalIPs), n.OSImage, n.KernelVersion, n.ContainerRuntime,
+			cpuUsage(n), memoryUsage(n), n.Pods, n.PodsAllocatable, mdTaints(n.Taints),
+		))
+	}
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+func mdList(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+func mdTaints(taints []string) string {
+	if len(taints) == 0 {
+		return "-"
+	}
+	quoted := make([]string, 0, len(taints))
+	for _, t := range taints {
+		quoted = append(quoted, "`"+t+"`")
+	}
+	return strings.Join(quoted, "<br>")
+}
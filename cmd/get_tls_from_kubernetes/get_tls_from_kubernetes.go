@@ -5,16 +5,16 @@ import (
 	"os"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/file_utils"
+	"github.com/sikalabs/slr/internal/k8s"
 	"github.com/sikalabs/slu/pkg/utils/error_utils"
 	"github.com/spf13/cobra"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 var FlagName string
-var FlagNamespace string
+var FlagK8s *k8s.Options
 var FlagFileCert string
 var FlagFileKey string
 
@@ -22,8 +22,7 @@ func init() {
 	root.Cmd.AddCommand(Cmd)
 	Cmd.Flags().StringVar(&FlagName, "name", "", "Secret name")
 	Cmd.MarkFlagRequired("name")
-	Cmd.Flags().StringVar(&FlagNamespace, "namespace", "", "Kubernetes namespace")
-	Cmd.MarkFlagRequired("namespace")
+	FlagK8s = k8s.AddFlags(Cmd)
 	Cmd.Flags().StringVar(&FlagFileCert, "file-cert", "", "File to write TLS certificate")
 	Cmd.MarkFlagRequired("file-cert")
 	Cmd.Flags().StringVar(&FlagFileKey, "file-key", "", "File to write TLS key")
@@ -34,31 +33,28 @@ var Cmd = &cobra.Command{
 	Use:  "get-tls-from-kubernetes",
 	Args: cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
-		getTlsFromKubernetes(FlagNamespace, FlagName, FlagFileCert, FlagFileKey)
+		getTlsFromKubernetes(FlagK8s, FlagName, FlagFileCert, FlagFileKey)
 	},
 }
 
-func getTlsFromKubernetes(namespace, name, fileCert, fileKey string) {
-	crt, key := getSecretOrDie(namespace, name)
-	writeFileOrDie(fileCert, crt)
-	writeFileOrDie(fileKey, key)
+func getTlsFromKubernetes(k8sOptions *k8s.Options, name, fileCert, fileKey string) {
+	crt, key := getSecretOrDie(k8sOptions, name)
+	writeFileOrDie(fileCert, crt, 0644)
+	// private key must not be readable by other users
+	writeFileOrDie(fileKey, key, 0600)
 }
 
-func writeFileOrDie(filename, content string) {
-	err := os.WriteFile(filename, []byte(content), 0644)
+func writeFileOrDie(filename, content string, mode os.FileMode) {
+	err := file_utils.WriteFileAtomic(filename, []byte(content), mode, "", "")
 	error_utils.HandleError(err)
 }
 
-func getSecretOrDie(namespace, name string) (string, string) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-	config, err := kubeConfig.ClientConfig()
+func getSecretOrDie(k8sOptions *k8s.Options, name string) (string, string) {
+	namespace, err := k8sOptions.GetNamespace()
 	error_utils.HandleError(err)
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := k8sOptions.Clientset()
 	error_utils.HandleError(err)
 	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 	error_utils.HandleError(err)
 	return string(secret.Data["tls.crt"]), string(secret.Data["tls.key"])
 }
-
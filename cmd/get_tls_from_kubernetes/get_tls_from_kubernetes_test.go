@@ -0,0 +1,54 @@
+package get_tls_from_kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sikalabs/slr/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetTlsFromKubernetes(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-tls", Namespace: "web"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("CERT"),
+			corev1.TLSPrivateKeyKey: []byte("KEY"),
+		},
+	})
+	options := (&k8s.Options{Namespace: "web"}).WithClientset(clientset)
+
+	dir := t.TempDir()
+	fileCert := filepath.Join(dir, "tls.crt")
+	fileKey := filepath.Join(dir, "tls.key")
+	getTlsFromKubernetes(options, "example-tls", fileCert, fileKey)
+
+	for _, f := range []struct {
+		path string
+		data string
+		mode os.FileMode
+	}{
+		{fileCert, "CERT", 0644},
+		{fileKey, "KEY", 0600},
+	} {
+		got, err := os.ReadFile(f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != f.data {
+			t.Fatalf("%s = %q, want %q", filepath.Base(f.path), got, f.data)
+		}
+		info, err := os.Stat(f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != f.mode {
+			t.Fatalf("%s has mode %04o, want %04o", filepath.Base(f.path), info.Mode().Perm(), f.mode)
+		}
+	}
+}
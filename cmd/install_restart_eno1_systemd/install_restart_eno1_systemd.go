@@ -15,6 +15,8 @@ var FlagInterface string
 var FlagTestURL string
 var FlagTimeout int
 var FlagLogFile string
+var FlagStateFile string
+var FlagMaxRestartsPerHour int
 var FlagTelegramBotToken string
 var FlagTelegramChatID string
 var FlagTelegramTimeout int
@@ -25,9 +27,11 @@ func init() {
 	Cmd.Flags().StringVarP(&FlagTestURL, "test-url", "u", "https://checkip.amazonaws.com/", "URL to test network connectivity")
 	Cmd.Flags().IntVarP(&FlagTimeout, "timeout", "t", 10, "Timeout in seconds for network test")
 	Cmd.Flags().StringVarP(&FlagLogFile, "log-file", "l", "/var/log/restart-eno1.log", "Log file path for JSON logs")
+	Cmd.Flags().StringVar(&FlagStateFile, "state-file", "/var/lib/restart-eno1/state.json", "State file with failures, restarts and queued notifications")
+	Cmd.Flags().IntVar(&FlagMaxRestartsPerHour, "max-restarts-per-hour", 5, "Restarts per hour after which restarts pause and flapping alert is sent (0 for unlimited)")
 	Cmd.Flags().StringVar(&FlagTelegramBotToken, "bot-token", "", "Telegram bot token for notifications")
 	Cmd.Flags().StringVarP(&FlagTelegramChatID, "chat-id", "c", "", "Telegram chat ID for notifications")
-	Cmd.Flags().IntVar(&FlagTelegramTimeout, "telegram-timeout", 300, "Timeout in seconds for telegram notification retry (default 5 minutes)")
+	Cmd.Flags().IntVar(&FlagTelegramTimeout, "telegram-timeout", 30, "Maximum seconds spent sending queued notifications per run, the rest is retried in the next run")
 }
 
 var Cmd = &cobra.Command{
@@ -35,7 +39,7 @@ var Cmd = &cobra.Command{
 	Short: "Install systemd service and timer to restart eno1 interface every minute",
 	Args:  cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
-		installSystemd(FlagInterface, FlagTestURL, FlagTimeout, FlagLogFile, FlagTelegramBotToken, FlagTelegramChatID, FlagTelegramTimeout)
+		installSystemd(FlagInterface, FlagTestURL, FlagTimeout, FlagLogFile, FlagStateFile, FlagMaxRestartsPerHour, FlagTelegramBotToken, FlagTelegramChatID, FlagTelegramTimeout)
 	},
 }
 
@@ -47,7 +51,7 @@ func getExecutablePath() (string, error) {
 	return filepath.Abs(execPath)
 }
 
-func installSystemd(interfaceName, testURL string, timeout int, logFile, telegramBotToken, telegramChatID string, telegramTimeout int) {
+func installSystemd(interfaceName, testURL string, timeout int, logFile, stateFile string, maxRestartsPerHour int, telegramBotToken, telegramChatID string, telegramTimeout int) {
 	execPath, err := getExecutablePath()
 	if err != nil {
 		log.Fatalf("Failed to get executable path: %v", err)
@@ -56,6 +60,7 @@ func installSystemd(interfaceName, testURL string, timeout int, logFile, telegra
 	fmt.Printf("Installing systemd service and timer for restart-eno1...\n")
 	fmt.Printf("Executable path: %s\n", execPath)
 	fmt.Printf("Log file: %s\n", logFile)
+	fmt.Printf("State file: %s\n", stateFile)
 	if telegramBotToken != "" && telegramChatID != "" {
 		fmt.Printf("Telegram notifications: enabled (chat ID: %s)\n", telegramChatID)
 	} else {
@@ -63,7 +68,7 @@ func installSystemd(interfaceName, testURL string, timeout int, logFile, telegra
 	}
 
 	// Build command with optional telegram parameters
-	execCmd := fmt.Sprintf("%s restart-eno1 --interface %s --test-url %s --timeout %d --log-file %s", execPath, interfaceName, testURL, timeout, logFile)
+	execCmd := fmt.Sprintf("%s restart-eno1 --interface %s --test-url %s --timeout %d --log-file %s --state-file %s --max-restarts-per-hour %d", execPath, interfaceName, testURL, timeout, logFile, stateFile, maxRestartsPerHour)
 	if telegramBotToken != "" && telegramChatID != "" {
 		execCmd += fmt.Sprintf(" --bot-token %s --chat-id %s --telegram-timeout %d", telegramBotToken, telegramChatID, telegramTimeout)
 	}
@@ -153,6 +158,7 @@ WantedBy=timers.target
 	fmt.Println("  systemctl status restart-eno1.service # Check service status")
 	fmt.Println("  journalctl -u restart-eno1.service    # View logs")
 	fmt.Printf("  tail -f %s                            # View JSON logs\n", logFile)
+	fmt.Printf("  cat %s                     # View state and queued notifications\n", stateFile)
 	fmt.Println("  systemctl stop restart-eno1.timer     # Stop timer")
 	fmt.Println("  systemctl disable restart-eno1.timer  # Disable timer")
 }
@@ -0,0 +1,140 @@
+package install_tls_sync
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/cmd/tls_sync"
+	"github.com/spf13/cobra"
+)
+
+var FlagConfig string
+var FlagOnCalendar string
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagConfig, "config", "c", tls_sync.DefaultConfigPath, "Path to tls-sync YAML config")
+	Cmd.Flags().StringVar(&FlagOnCalendar, "on-calendar", "daily", "systemd OnCalendar expression of the timer")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "install-tls-sync",
+	Short: "Install systemd service and timer to run tls-sync periodically",
+	Args:  cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		installSystemd(FlagConfig, FlagOnCalendar)
+	},
+}
+
+func installSystemd(config, onCalendar string) {
+	execPath, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to get executable path: %v", err)
+	}
+	execPath, err = filepath.Abs(execPath)
+	if err != nil {
+		log.Fatalf("Failed to get executable path: %v", err)
+	}
+	config, err = filepath.Abs(config)
+	if err != nil {
+		log.Fatalf("Failed to get config path: %v", err)
+	}
+
+	if _, err := os.Stat(config); err != nil {
+		log.Fatalf("Config file %s not found: %v", config, err)
+	}
+
+	fmt.Printf("Installing systemd service and timer for slr tls-sync...\n")
+	fmt.Printf("Executable path: %s\n", execPath)
+	fmt.Printf("Config: %s\n", config)
+
+	// Create service file content
+	serviceContent := fmt.Sprintf(`[Unit]
+Description=Sync TLS certificates (slr tls-sync)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=%s tls-sync --config %s
+StandardOutput=journal
+StandardError=journal
+
+[Install]
+WantedBy=multi-user.target
+`, execPath, config)
+
+	// Create timer file content
+	timerContent := fmt.Sprintf(`[Unit]
+Description=Run tls-sync %s
+Requires=tls-sync.service
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+Unit=tls-sync.service
+
+[Install]
+WantedBy=timers.target
+`, onCalendar, onCalendar)
+
+	servicePath := "/etc/systemd/system/tls-sync.service"
+	timerPath := "/etc/systemd/system/tls-sync.timer"
+
+	// Write service file
+	fmt.Printf("Writing service file to %s...\n", servicePath)
+	err = os.WriteFile(servicePath, []byte(serviceContent), 0644)
+	if err != nil {
+		log.Fatalf("Failed to write service file: %v", err)
+	}
+
+	// Write timer file
+	fmt.Printf("Writing timer file to %s...\n", timerPath)
+	err = os.WriteFile(timerPath, []byte(timerContent), 0644)
+	if err != nil {
+		log.Fatalf("Failed to write timer file: %v", err)
+	}
+
+	// Reload systemd
+	fmt.Println("Reloading systemd daemon...")
+	cmd := exec.Command("systemctl", "daemon-reload")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Fatalf("Failed to reload systemd: %v, output: %s", err, string(output))
+	}
+
+	// Enable timer
+	fmt.Println("Enabling tls-sync.timer...")
+	cmd = exec.Command("systemctl", "enable", "tls-sync.timer")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		log.Fatalf("Failed to enable timer: %v, output: %s", err, string(output))
+	}
+	fmt.Printf("Output: %s\n", string(output))
+
+	// Start timer
+	fmt.Println("Starting tls-sync.timer...")
+	cmd = exec.Command("systemctl", "start", "tls-sync.timer")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		log.Fatalf("Failed to start timer: %v, output: %s", err, string(output))
+	}
+
+	// Show status
+	fmt.Println("\nTimer status:")
+	cmd = exec.Command("systemctl", "status", "tls-sync.timer", "--no-pager")
+	output, _ = cmd.CombinedOutput()
+	fmt.Printf("%s\n", string(output))
+
+	fmt.Println("\n=== Installation complete ===")
+	fmt.Printf("The tls-sync command will now run %s.\n", onCalendar)
+	fmt.Println("\nUseful commands:")
+	fmt.Println("  systemctl start tls-sync.service    # Run sync now")
+	fmt.Println("  systemctl status tls-sync.timer     # Check timer status")
+	fmt.Println("  journalctl -u tls-sync.service      # View logs")
+	fmt.Println("  systemctl disable tls-sync.timer    # Disable timer")
+}
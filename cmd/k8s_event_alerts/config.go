@@ -0,0 +1,161 @@
+package k8s_event_alerts
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/sikalabs/slr/internal/env_utils"
+	"github.com/sikalabs/slr/internal/time_utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	NotifyTelegram = "telegram"
+	NotifyEmail    = "email"
+)
+
+// Example config (${VAR} is expanded from environment):
+//
+//	ClusterName: prod
+//	Telegram:
+//	  BotToken: ${TELEGRAM_BOT_TOKEN}
+//	  ChatID: -1001234567890
+//	Email:
+//	  SmtpHost: smtp.example.com
+//	  SmtpPassword: ${SMTP_PASSWORD}
+//	  From: alerts@example.com
+//	  To: [ops@example.com]
+//	Rules:
+//	  - Name: crashloop
+//	    Reasons: [BackOff, FailedScheduling, OOMKilling]
+//	    Namespaces: ["prod-*"]
+//	    DedupWindow: 30m
+//	    RateLimit:
+//	      Count: 10
+//	      Per: 1h
+type Config struct {
+	ClusterName string    `yaml:"ClusterName"`
+	Telegram    *Telegram `yaml:"Telegram"`
+	Email       *Email    `yaml:"Email"`
+	Rules       []Rule    `yaml:"Rules"`
+}
+
+type Telegram struct {
+	BotToken string `yaml:"BotToken"`
+	ChatID   int64  `yaml:"ChatID"`
+}
+
+type Email struct {
+	SmtpHost     string   `yaml:"SmtpHost"`
+	SmtpPort     int      `yaml:"SmtpPort"`
+	SmtpUser     string   `yaml:"SmtpUser"`
+	SmtpPassword string   `yaml:"SmtpPassword"`
+	From         string   `yaml:"From"`
+	To           []string `yaml:"To"`
+}
+
+// Rule matches an event when all non-empty conditions match. Namespaces
+// and ExcludeNamespaces are globs (prod-*), Types defaults to Warning.
+type Rule struct {
+	Name              string     `yaml:"Name"`
+	Reasons           []string   `yaml:"Reasons"`
+	Types             []string   `yaml:"Types"`
+	Kinds             []string   `yaml:"Kinds"`
+	Namespaces        []string   `yaml:"Namespaces"`
+	ExcludeNamespaces []string   `yaml:"ExcludeNamespaces"`
+	MessageRegex      string     `yaml:"MessageRegex"`
+	DedupWindow       string     `yaml:"DedupWindow"`
+	RateLimit         *RateLimit `yaml:"RateLimit"`
+	// telegram, email or both (default: all configured)
+	Notify []string `yaml:"Notify"`
+
+	messageRegex *regexp.Regexp
+	dedupWindow  time.Duration
+	ratePer      time.Duration
+}
+
+// RateLimit allows at most Count alerts of the rule per Per (e.g. 1h)
+type RateLimit struct {
+	Count int    `yaml:"Count"`
+	Per   string `yaml:"Per"`
+}
+
+const defaultDedupWindow = 15 * time.Minute
+
+func loadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal([]byte(env_utils.Expand(string(data))), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("config has no Rules")
+	}
+	if config.Email != nil && config.Email.SmtpPort == 0 {
+		config.Email.SmtpPort = 587
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule #%d has no Name", i)
+		}
+		if len(rule.Types) == 0 {
+			rule.Types = []string{"Warning"}
+		}
+		for _, pattern := range append(rule.Namespaces, rule.ExcludeNamespaces...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid namespace glob %q", rule.Name, pattern)
+			}
+		}
+		if rule.MessageRegex != "" {
+			rule.messageRegex, err = regexp.Compile(rule.MessageRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid MessageRegex: %w", rule.Name, err)
+			}
+		}
+
+		rule.dedupWindow = defaultDedupWindow
+		if rule.DedupWindow != "" {
+			rule.dedupWindow, err = time_utils.ParseDuration(rule.DedupWindow)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid DedupWindow: %w", rule.Name, err)
+			}
+		}
+
+		if rule.RateLimit != nil {
+			if rule.RateLimit.Count < 1 {
+				return nil, fmt.Errorf("rule %s: RateLimit.Count must be at least 1", rule.Name)
+			}
+			rule.ratePer, err = time_utils.ParseDuration(rule.RateLimit.Per)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid RateLimit.Per: %w", rule.Name, err)
+			}
+		}
+
+		for _, n := range rule.Notify {
+			switch n {
+			case NotifyTelegram:
+				if config.Telegram == nil {
+					return nil, fmt.Errorf("rule %s: notify telegram but Telegram is not configured", rule.Name)
+				}
+			case NotifyEmail:
+				if config.Email == nil {
+					return nil, fmt.Errorf("rule %s: notify email but Email is not configured", rule.Name)
+				}
+			default:
+				return nil, fmt.Errorf("rule %s: unknown Notify %q (telegram, email)", rule.Name, n)
+			}
+		}
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,243 @@
+package k8s_event_alerts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slu/utils/mail_utils"
+	"github.com/sikalabs/slu/utils/telegram_utils"
+	"github.com/spf13/cobra"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+var FlagConfig string
+var FlagK8s *k8s.Options
+var FlagNamespace string
+var FlagDryRun bool
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagConfig, "config", "c", "k8s-event-alerts.yaml", "Path to k8s-event-alerts YAML config")
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	Cmd.Flags().StringVarP(&FlagNamespace, "namespace", "n", "", "Watch events only in namespace (default: all namespaces)")
+	Cmd.Flags().BoolVar(&FlagDryRun, "dry-run", false, "Print alerts instead of sending them")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "k8s-event-alerts",
+	Short: "Watch Kubernetes events and send Telegram or email alerts by YAML rules",
+	Args:  cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		err := k8sEventAlerts(FlagConfig, FlagK8s, FlagNamespace, FlagDryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func k8sEventAlerts(configPath string, k8sOptions *k8s.Options, namespace string, dryRun bool) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := k8sOptions.Clientset()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := &alerter{config: config, limiter: newLimiter(), dryRun: dryRun}
+	events := eventsWatcher{clientset.CoreV1().Events(namespace)}
+
+	for {
+		// Alert only on events which happen from now, the list is used
+		// only to get current resourceVersion
+		list, err := events.List(ctx, metav1.ListOptions{Limit: 1})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list events: %w", err)
+		}
+
+		fmt.Printf("Watching events (namespace=%q) with %d rules from resourceVersion %s\n", namespace, len(config.Rules), list.ResourceVersion)
+		expired, err := a.watch(ctx, events, list.ResourceVersion)
+		if err != nil || !expired {
+			return err
+		}
+		fmt.Printf("resourceVersion is too old, starting again\n")
+	}
+}
+
+// eventsWatcher implements cache.WatcherWithContext for RetryWatcher
+type eventsWatcher struct {
+	typedcorev1.EventInterface
+}
+
+func (w eventsWatcher) WatchWithContext(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	options.AllowWatchBookmarks = true
+	return w.Watch(ctx, options)
+}
+
+type alerter struct {
+	config  *Config
+	limiter *limiter
+	dryRun  bool
+}
+
+// watch returns expired=true on 410 Gone, the caller starts a new watch
+// from current resourceVersion
+func (a *alerter) watch(ctx context.Context, events eventsWatcher, resourceVersion string) (expired bool, err error) {
+	watcher, err := watchtools.NewRetryWatcherWithContext(ctx, resourceVersion, events)
+	if err != nil {
+		return false, fmt.Errorf("failed to watch events: %w", err)
+	}
+	defer watcher.Stop()
+
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case now := <-prune.C:
+			a.limiter.Prune(now, a.maxDedupWindow())
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+
+			switch event.Type {
+			case watch.Error:
+				statusErr := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(statusErr) || apierrors.IsGone(statusErr) {
+					return true, nil
+				}
+				fmt.Fprintf(os.Stderr, "Error: watch: %v\n", statusErr)
+			case watch.Added, watch.Modified:
+				if e, ok := event.Object.(*corev1.Event); ok {
+					a.handle(e)
+				}
+			}
+		}
+	}
+}
+
+func (a *alerter) maxDedupWindow() time.Duration {
+	max := defaultDedupWindow
+	for _, rule := range a.config.Rules {
+		if rule.dedupWindow > max {
+			max = rule.dedupWindow
+		}
+	}
+	return max
+}
+
+// handle sends alert for the first matching rule, rules are evaluated in
+// order of the config
+func (a *alerter) handle(e *corev1.Event) {
+	for i := range a.config.Rules {
+		rule := &a.config.Rules[i]
+		if !rule.Match(e) {
+			continue
+		}
+
+		ok, suppressed := a.limiter.Allow(rule, e, time.Now())
+		if !ok {
+			return
+		}
+
+		subject, message := a.format(rule, e, suppressed)
+		if a.dryRun {
+			fmt.Printf("%s\n%s\n\n", subject, message)
+			return
+		}
+		a.notify(rule, subject, message)
+		return
+	}
+}
+
+func (a *alerter) format(rule *Rule, e *corev1.Event, suppressed int) (string, string) {
+	o := e.InvolvedObject
+	object := o.Kind + "/" + o.Name
+	if o.Namespace != "" {
+		object = o.Namespace + "/" + object
+	}
+
+	prefix := ""
+	if a.config.ClusterName != "" {
+		prefix = "[" + a.config.ClusterName + "] "
+	}
+	subject := fmt.Sprintf("%s%s: %s %s", prefix, rule.Name, e.Reason, object)
+
+	lines := []string{
+		fmt.Sprintf("%s %s", e.Type, e.Reason),
+		object,
+		e.Message,
+	}
+	if e.Count > 1 {
+		lines = append(lines, fmt.Sprintf("count: %d", e.Count))
+	}
+	if suppressed > 0 {
+		lines = append(lines, fmt.Sprintf("(%d alerts of rule %s suppressed by rate limit)", suppressed, rule.Name))
+	}
+	return subject, strings.Join(lines, "\n")
+}
+
+func (a *alerter) notify(rule *Rule, subject, message string) {
+	notify := rule.Notify
+	if len(notify) == 0 {
+		if a.config.Telegram != nil {
+			notify = append(notify, NotifyTelegram)
+		}
+		if a.config.Email != nil {
+			notify = append(notify, NotifyEmail)
+		}
+	}
+	if len(notify) == 0 {
+		fmt.Printf("%s\n%s\n\n", subject, message)
+		return
+	}
+
+	for _, n := range notify {
+		switch n {
+		case NotifyTelegram:
+			t := a.config.Telegram
+			err := telegram_utils.TelegramSendMessage(t.BotToken, t.ChatID, subject+"\n\n"+message)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to send Telegram notification: %v\n", err)
+			}
+		case NotifyEmail:
+			m := a.config.Email
+			user := m.From
+			if m.SmtpUser != "" {
+				user = m.SmtpUser
+			}
+			for _, to := range m.To {
+				err := mail_utils.SendSimpleMail(m.SmtpHost, strconv.Itoa(m.SmtpPort), user, m.SmtpPassword, m.From, to, subject, message)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to send email notification to %s: %v\n", to, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package k8s_event_alerts
+
+import (
+	"path"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func (r *Rule) Match(e *corev1.Event) bool {
+	if len(r.Reasons) > 0 && !slices.Contains(r.Reasons, e.Reason) {
+		return false
+	}
+	if !slices.Contains(r.Types, e.Type) {
+		return false
+	}
+	if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, e.InvolvedObject.Kind) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !matchGlob(r.Namespaces, e.Namespace) {
+		return false
+	}
+	if matchGlob(r.ExcludeNamespaces, e.Namespace) {
+		return false
+	}
+	if r.messageRegex != nil && !r.messageRegex.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+func matchGlob(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter decides if an alert is sent. The same involved object and
+// reason is alerted once per rule's DedupWindow, and at most RateLimit
+// alerts per rule are sent in a sliding window.
+type limiter struct {
+	lastSent   map[string]time.Time
+	sent       map[string][]time.Time
+	suppressed map[string]int
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		lastSent:   map[string]time.Time{},
+		sent:       map[string][]time.Time{},
+		suppressed: map[string]int{},
+	}
+}
+
+func dedupKey(rule *Rule, e *corev1.Event) string {
+	o := e.InvolvedObject
+	uid := string(o.UID)
+	if uid == "" {
+		uid = o.Namespace + "/" + o.Kind + "/" + o.Name
+	}
+	return rule.Name + "\x00" + uid + "\x00" + e.Reason
+}
+
+// Allow returns true if the alert should be sent and the number of alerts
+// of the rule suppressed by rate limit since the last sent one
+func (l *limiter) Allow(rule *Rule, e *corev1.Event, now time.Time) (bool, int) {
+	key := dedupKey(rule, e)
+	if last, ok := l.lastSent[key]; ok && now.Sub(last) < rule.dedupWindow {
+		return false, 0
+	}
+
+	if rule.RateLimit != nil {
+		sent := l.sent[rule.Name]
+		for len(sent) > 0 && now.Sub(sent[0]) >= rule.ratePer {
+			sent = sent[1:]
+		}
+		l.sent[rule.Name] = sent
+		if len(sent) >= rule.RateLimit.Count {
+			l.suppressed[rule.Name]++
+			return false, 0
+		}
+		l.sent[rule.Name] = append(sent, now)
+	}
+
+	l.lastSent[key] = now
+	suppressed := l.suppressed[rule.Name]
+	l.suppressed[rule.Name] = 0
+	return true, suppressed
+}
+
+// Prune drops dedup entries older than maxAge, so memory doesn't grow
+// with every pod ever alerted
+func (l *limiter) Prune(now time.Time, maxAge time.Duration) {
+	for key, last := range l.lastSent {
+		if now.Sub(last) > maxAge {
+			delete(l.lastSent, key)
+		}
+	}
+}
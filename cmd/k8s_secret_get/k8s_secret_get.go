@@ -0,0 +1,248 @@
+package k8s_secret_get
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/file_utils"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/spf13/cobra"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	OutputDotenv = "dotenv"
+	OutputJSON   = "json"
+)
+
+var FlagK8s *k8s.Options
+var FlagKeys []string
+var FlagOwner string
+var FlagGroup string
+var FlagOutput string
+var FlagOutputFile string
+var FlagOutputMode string
+var FlagWatch bool
+var FlagOnChange string
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	FlagK8s = k8s.AddFlags(Cmd)
+	Cmd.Flags().StringArrayVar(&FlagKeys, "key", nil, "Write secret key to file as key=path[:mode] (mode defaults to 0600), can be repeated")
+	Cmd.Flags().StringVar(&FlagOwner, "owner", "", "Owner (user name or uid) of written files")
+	Cmd.Flags().StringVar(&FlagGroup, "group", "", "Group (name or gid) of written files")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "Print all keys as dotenv or json")
+	Cmd.Flags().StringVar(&FlagOutputFile, "output-file", "", "Write --output to file instead of stdout")
+	Cmd.Flags().StringVar(&FlagOutputMode, "output-mode", "0600", "Mode of --output-file")
+	Cmd.Flags().BoolVarP(&FlagWatch, "watch", "w", false, "Keep running and rewrite files when the secret changes")
+	Cmd.Flags().StringVar(&FlagOnChange, "on-change", "", "Shell command (sh -c) run when any file was changed")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "k8s-secret-get <secret>",
+	Short: "Extract Kubernetes secret keys to files, dotenv or json",
+	Example: `  slr k8s-secret-get -n gitlab-proxy gitlab-tls \
+    --key tls.crt=/etc/ssl/gitlab.crt:0644 --key tls.key=/etc/ssl/gitlab.key \
+    --on-change "systemctl reload nginx" --watch
+
+  slr k8s-secret-get -n app db-credentials -o dotenv --output-file /etc/app/db.env`,
+	Args: cobra.ExactArgs(1),
+	Run: func(c *cobra.Command, args []string) {
+		err := k8sSecretGet(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+type keyFile struct {
+	key  string
+	path string
+	mode os.FileMode
+}
+
+var modeSuffix = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// parseKeyFile parses key=path[:mode], mode is recognized only as octal
+// number after the last colon, so paths with colons work
+func parseKeyFile(s string) (keyFile, error) {
+	key, path, ok := strings.Cut(s, "=")
+	if !ok || key == "" || path == "" {
+		return keyFile{}, fmt.Errorf("--key must be in format key=path[:mode], got: %s", s)
+	}
+
+	mode := os.FileMode(0600)
+	if i := strings.LastIndex(path, ":"); i > 0 && modeSuffix.MatchString(path[i+1:]) {
+		var err error
+		mode, err = file_utils.ParseMode(path[i+1:], 0600)
+		if err != nil {
+			return keyFile{}, err
+		}
+		path = path[:i]
+	}
+	return keyFile{key: key, path: path, mode: mode}, nil
+}
+
+type secretGetter struct {
+	files      []keyFile
+	outputMode os.FileMode
+}
+
+func k8sSecretGet(name string) error {
+	if len(FlagKeys) == 0 && FlagOutput == "" {
+		return fmt.Errorf("nothing to do, use --key or --output")
+	}
+	if FlagOutput != "" && FlagOutput != OutputDotenv && FlagOutput != OutputJSON {
+		return fmt.Errorf("unknown output %q (dotenv, json)", FlagOutput)
+	}
+	if FlagWatch && FlagOutput != "" && FlagOutputFile == "" {
+		return fmt.Errorf("--watch with --output requires --output-file")
+	}
+
+	g := &secretGetter{}
+	for _, s := range FlagKeys {
+		f, err := parseKeyFile(s)
+		if err != nil {
+			return err
+		}
+		g.files = append(g.files, f)
+	}
+	var err error
+	g.outputMode, err = file_utils.ParseMode(FlagOutputMode, 0600)
+	if err != nil {
+		return err
+	}
+	// fail early on unknown owner, not after the secret was fetched
+	if _, _, err := file_utils.LookupOwner(FlagOwner, FlagGroup); err != nil {
+		return err
+	}
+
+	namespace, err := FlagK8s.GetNamespace()
+	if err != nil {
+		return err
+	}
+	clientset, err := FlagK8s.Clientset()
+	if err != nil {
+		return err
+	}
+	secrets := clientset.CoreV1().Secrets(namespace)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if err := g.apply(secret); err != nil {
+		return err
+	}
+	if !FlagWatch {
+		return nil
+	}
+
+	return g.watch(ctx, secrets, name, secret.ResourceVersion)
+}
+
+// apply writes changed files and runs --on-change hook if any was written
+func (g *secretGetter) apply(secret *corev1.Secret) error {
+	changed := false
+	for _, f := range g.files {
+		data, ok := secret.Data[f.key]
+		if !ok {
+			return fmt.Errorf("secret %s/%s has no key %s", secret.Namespace, secret.Name, f.key)
+		}
+		c, err := writeIfChanged(f.path, data, f.mode)
+		if err != nil {
+			return err
+		}
+		changed = changed || c
+	}
+
+	if FlagOutput != "" {
+		data, err := format(secret.Data, FlagOutput)
+		if err != nil {
+			return err
+		}
+		if FlagOutputFile == "" {
+			os.Stdout.Write(data)
+		} else {
+			c, err := writeIfChanged(FlagOutputFile, data, g.outputMode)
+			if err != nil {
+				return err
+			}
+			changed = changed || c
+		}
+	}
+
+	if !changed || FlagOnChange == "" {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Running on-change: %s\n", FlagOnChange)
+	cmd := exec.Command("sh", "-c", FlagOnChange)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("on-change command failed: %w", err)
+	}
+	return nil
+}
+
+// writeIfChanged writes file atomically (with --owner and --group) only
+// if the content differs, mode is applied on every write
+func writeIfChanged(path string, data []byte, mode os.FileMode) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+	if err := file_utils.WriteFileAtomic(path, data, mode, FlagOwner, FlagGroup); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(os.Stderr, "Written %s\n", path)
+	return true, nil
+}
+
+var notEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// format returns all keys as dotenv (keys upper-cased, other characters
+// than A-Z, 0-9 and _ replaced by _, e.g. tls.crt -> TLS_CRT) or json
+func format(data map[string][]byte, output string) ([]byte, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if output == OutputJSON {
+		values := make(map[string]string, len(data))
+		for _, k := range keys {
+			values[k] = string(data[k])
+		}
+		out, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	}
+
+	var out bytes.Buffer
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`)
+	for _, k := range keys {
+		name := notEnvChars.ReplaceAllString(strings.ToUpper(k), "_")
+		fmt.Fprintf(&out, "%s=\"%s\"\n", name, replacer.Replace(string(data[k])))
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package k8s_secret_get
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// secretWatcher implements cache.WatcherWithContext for RetryWatcher,
+// watching only one secret
+type secretWatcher struct {
+	secrets typedcorev1.SecretInterface
+	name    string
+}
+
+func (w secretWatcher) WatchWithContext(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	options.FieldSelector = fields.OneTermEqualSelector("metadata.name", w.name).String()
+	options.AllowWatchBookmarks = true
+	return w.secrets.Watch(ctx, options)
+}
+
+// watch applies every change of the secret until ctx is done. Errors of
+// apply (e.g. missing key) are printed and the previous files are kept.
+func (g *secretGetter) watch(ctx context.Context, secrets typedcorev1.SecretInterface, name, resourceVersion string) error {
+	fmt.Fprintf(os.Stderr, "Watching secret %s for changes\n", name)
+	for {
+		watcher, err := watchtools.NewRetryWatcherWithContext(ctx, resourceVersion, secretWatcher{secrets: secrets, name: name})
+		if err != nil {
+			return err
+		}
+
+		expired := false
+		for event := range watcher.ResultChan() {
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				secret, ok := event.Object.(*corev1.Secret)
+				if !ok {
+					continue
+				}
+				if err := g.apply(secret); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+			case watch.Deleted:
+				fmt.Fprintf(os.Stderr, "Secret %s was deleted, keeping files\n", name)
+			case watch.Error:
+				statusErr := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(statusErr) || apierrors.IsGone(statusErr) {
+					expired = true
+				} else {
+					fmt.Fprintf(os.Stderr, "Error: watch: %v\n", statusErr)
+				}
+			}
+		}
+		watcher.Stop()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !expired {
+			return fmt.Errorf("watch of secret %s stopped", name)
+		}
+
+		// resourceVersion is too old, get the current state (it may have
+		// changed meanwhile) and watch again
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := g.apply(secret); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		resourceVersion = secret.ResourceVersion
+	}
+}
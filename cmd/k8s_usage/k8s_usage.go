@@ -0,0 +1,277 @@
+package k8s_usage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/spf13/cobra"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var FlagNamespace string
+var FlagSelector string
+var FlagOutput string
+var FlagGroupBy string
+var FlagLowUsage float64
+var FlagNearLimit float64
+var FlagHeadroom float64
+var FlagOnlyFlagged bool
+var FlagK8s *k8s.Options
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagNamespace, "namespace", "n", "", "Namespace (default: all namespaces)")
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	Cmd.Flags().StringVarP(&FlagSelector, "selector", "l", "", "Pod label selector")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", OutputTable, "Output format (table, json)")
+	Cmd.Flags().StringVar(&FlagGroupBy, "group-by", GroupByContainer, "Aggregate by namespace, workload or container")
+	Cmd.Flags().Float64Var(&FlagLowUsage, "low-usage", 0.3, "Flag containers using less than this fraction of requests")
+	Cmd.Flags().Float64Var(&FlagNearLimit, "near-limit", 0.9, "Flag containers using more than this fraction of limits")
+	Cmd.Flags().Float64Var(&FlagHeadroom, "headroom", 1.3, "Suggested request is current usage multiplied by headroom")
+	Cmd.Flags().BoolVar(&FlagOnlyFlagged, "only-flagged", false, "Show only flagged containers (with --group-by container)")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "k8s-usage",
+	Short: "Compare pod requests and limits with metrics-server usage, suggest requests",
+	Long: `Compare pod requests and limits with current usage from metrics-server.
+
+Usage is a point-in-time value from metrics-server, run it at a
+representative time (e.g. during a training lab, not at night).`,
+	Args: cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		err := k8sUsage()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+const (
+	GroupByNamespace = "namespace"
+	GroupByWorkload  = "workload"
+	GroupByContainer = "container"
+
+	IssueOverRequestedCPU = "over-requested-cpu"
+	IssueOverRequestedMem = "over-requested-memory"
+	IssueNearCPULimit     = "near-cpu-limit"
+	IssueNearMemLimit     = "near-memory-limit"
+	IssueNoRequests       = "no-requests"
+)
+
+// Usage is one row of the report, CPU is in millicores and memory in
+// bytes. Suggested requests are per pod (container group-by only).
+type Usage struct {
+	Namespace       string   `json:"namespace"`
+	Workload        string   `json:"workload,omitempty"`
+	Container       string   `json:"container,omitempty"`
+	Pods            int      `json:"pods"`
+	CPUUsage        int64    `json:"cpuUsageMillicores"`
+	CPURequest      int64    `json:"cpuRequestMillicores"`
+	CPULimit        int64    `json:"cpuLimitMillicores"`
+	MemoryUsage     int64    `json:"memoryUsageBytes"`
+	MemoryRequest   int64    `json:"memoryRequestBytes"`
+	MemoryLimit     int64    `json:"memoryLimitBytes"`
+	SuggestedCPU    int64    `json:"suggestedCpuRequestMillicores,omitempty"`
+	SuggestedMemory int64    `json:"suggestedMemoryRequestBytes,omitempty"`
+	Flags           []string `json:"flags,omitempty"`
+
+	maxPodCPU    int64
+	maxPodMemory int64
+	// highest usage to limit ratio of a single pod, limits are per pod,
+	// sums of the group would hide one pod close to its limit
+	maxPodCPULimitRatio    float64
+	maxPodMemoryLimitRatio float64
+}
+
+func k8sUsage() error {
+	write, ok := formatters[FlagOutput]
+	if !ok {
+		return fmt.Errorf("unknown output %q (table, json)", FlagOutput)
+	}
+	if FlagGroupBy != GroupByNamespace && FlagGroupBy != GroupByWorkload && FlagGroupBy != GroupByContainer {
+		return fmt.Errorf("unknown --group-by %q (namespace, workload, container)", FlagGroupBy)
+	}
+
+	clientset, err := FlagK8s.Clientset()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.TODO()
+	pods, err := clientset.CoreV1().Pods(FlagNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: FlagSelector,
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	metrics, err := getPodMetrics(ctx, clientset, FlagNamespace, FlagSelector)
+	if err != nil {
+		return err
+	}
+
+	rows := aggregate(pods.Items, metrics, FlagGroupBy)
+	if FlagGroupBy == GroupByContainer {
+		for _, row := range rows {
+			analyze(row, FlagLowUsage, FlagNearLimit, FlagHeadroom)
+		}
+		if FlagOnlyFlagged {
+			flagged := rows[:0]
+			for _, row := range rows {
+				if len(row.Flags) > 0 {
+					flagged = append(flagged, row)
+				}
+			}
+			rows = flagged
+		}
+	}
+
+	return write(os.Stdout, rows, FlagGroupBy)
+}
+
+// aggregate sums requests, limits and usage of running containers by the
+// group, pods without metrics (just started) are skipped
+func aggregate(pods []corev1.Pod, metrics map[string]containerUsage, groupBy string) []*Usage {
+	rows := map[string]*Usage{}
+	podsSeen := map[string]map[string]bool{}
+
+	for i := range pods {
+		pod := &pods[i]
+		workload := workloadName(pod)
+		for _, c := range pod.Spec.Containers {
+			usage, ok := metrics[pod.Namespace+"/"+pod.Name+"/"+c.Name]
+			if !ok {
+				continue
+			}
+
+			key := pod.Namespace
+			row := &Usage{Namespace: pod.Namespace}
+			switch groupBy {
+			case GroupByWorkload:
+				key += "/" + workload
+				row.Workload = workload
+			case GroupByContainer:
+				key += "/" + workload + "/" + c.Name
+				row.Workload = workload
+				row.Container = c.Name
+			}
+			if existing, ok := rows[key]; ok {
+				row = existing
+			} else {
+				rows[key] = row
+				podsSeen[key] = map[string]bool{}
+			}
+			if !podsSeen[key][pod.Name] {
+				podsSeen[key][pod.Name] = true
+				row.Pods++
+			}
+
+			row.CPUUsage += usage.cpu
+			row.MemoryUsage += usage.memory
+			row.CPURequest += c.Resources.Requests.Cpu().MilliValue()
+			row.MemoryRequest += c.Resources.Requests.Memory().Value()
+			row.CPULimit += c.Resources.Limits.Cpu().MilliValue()
+			row.MemoryLimit += c.Resources.Limits.Memory().Value()
+			row.maxPodCPU = max(row.maxPodCPU, usage.cpu)
+			row.maxPodMemory = max(row.maxPodMemory, usage.memory)
+			if limit := c.Resources.Limits.Cpu().MilliValue(); limit > 0 {
+				row.maxPodCPULimitRatio = max(row.maxPodCPULimitRatio, float64(usage.cpu)/float64(limit))
+			}
+			if limit := c.Resources.Limits.Memory().Value(); limit > 0 {
+				row.maxPodMemoryLimitRatio = max(row.maxPodMemoryLimitRatio, float64(usage.memory)/float64(limit))
+			}
+		}
+	}
+
+	result := make([]*Usage, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		return a.Container < b.Container
+	})
+	return result
+}
+
+// analyze flags the container (near limit if any of its pods is) and
+// computes suggested per pod requests from the busiest pod (rounded up to
+// 5m CPU and 16Mi memory)
+func analyze(row *Usage, lowUsage, nearLimit, headroom float64) {
+	if row.CPURequest == 0 && row.MemoryRequest == 0 {
+		row.Flags = append(row.Flags, IssueNoRequests)
+	}
+	if row.CPURequest > 0 && float64(row.CPUUsage) < lowUsage*float64(row.CPURequest) {
+		row.Flags = append(row.Flags, IssueOverRequestedCPU)
+	}
+	if row.MemoryRequest > 0 && float64(row.MemoryUsage) < lowUsage*float64(row.MemoryRequest) {
+		row.Flags = append(row.Flags, IssueOverRequestedMem)
+	}
+	if row.maxPodCPULimitRatio > nearLimit {
+		row.Flags = append(row.Flags, IssueNearCPULimit)
+	}
+	if row.maxPodMemoryLimitRatio > nearLimit {
+		row.Flags = append(row.Flags, IssueNearMemLimit)
+	}
+
+	row.SuggestedCPU = roundUp(int64(float64(row.maxPodCPU)*headroom), 5)
+	row.SuggestedMemory = roundUp(int64(float64(row.maxPodMemory)*headroom), 16<<20)
+}
+
+func roundUp(value, step int64) int64 {
+	if value <= 0 {
+		return step
+	}
+	return (value + step - 1) / step * step
+}
+
+// workloadName returns Kind/name of the top level owner, ReplicaSet of
+// Deployment and Job of CronJob are resolved from names (pod-template-hash
+// label, CronJob's Job name suffix) without extra API calls
+func workloadName(pod *corev1.Pod) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod/" + pod.Name
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		if hash := pod.Labels["pod-template-hash"]; hash != "" {
+			if name, ok := strings.CutSuffix(owner.Name, "-"+hash); ok {
+				return "Deployment/" + name
+			}
+		}
+	case "Job":
+		if i := strings.LastIndex(owner.Name, "-"); i > 0 && isDigits(owner.Name[i+1:]) {
+			return "CronJob/" + owner.Name[:i]
+		}
+	}
+	return owner.Kind + "/" + owner.Name
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package k8s_usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podMetrics is the part of metrics.k8s.io/v1beta1 PodMetrics we need,
+// decoded directly to avoid dependency on k8s.io/metrics
+type podMetrics struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Containers []struct {
+		Name  string `json:"name"`
+		Usage struct {
+			CPU    resource.Quantity `json:"cpu"`
+			Memory resource.Quantity `json:"memory"`
+		} `json:"usage"`
+	} `json:"containers"`
+}
+
+type podMetricsList struct {
+	Items []podMetrics `json:"items"`
+}
+
+type containerUsage struct {
+	cpu    int64 // millicores
+	memory int64 // bytes
+}
+
+// getPodMetrics returns usage from metrics-server by namespace/pod/container
+func getPodMetrics(ctx context.Context, clientset kubernetes.Interface, namespace, selector string) (map[string]containerUsage, error) {
+	req := clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1")
+	if namespace != "" {
+		req = req.Namespace(namespace)
+	}
+	req = req.Resource("pods")
+	if selector != "" {
+		req = req.Param("labelSelector", selector)
+	}
+
+	data, err := req.DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics (is metrics-server installed?): %w", err)
+	}
+
+	var list podMetricsList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pod metrics: %w", err)
+	}
+
+	usage := map[string]containerUsage{}
+	for _, pod := range list.Items {
+		for _, c := range pod.Containers {
+			usage[pod.Metadata.Namespace+"/"+pod.Metadata.Name+"/"+c.Name] = containerUsage{
+				cpu:    c.Usage.CPU.MilliValue(),
+				memory: c.Usage.Memory.Value(),
+			}
+		}
+	}
+	return usage, nil
+}
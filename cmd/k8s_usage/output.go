@@ -0,0 +1,79 @@
+package k8s_usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+var formatters = map[string]func(io.Writer, []*Usage, string) error{
+	OutputTable: writeTable,
+	OutputJSON:  writeJSON,
+}
+
+func writeJSON(w io.Writer, rows []*Usage, _ string) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+func writeTable(w io.Writer, rows []*Usage, groupBy string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := []string{"NAMESPACE"}
+	if groupBy != GroupByNamespace {
+		header = append(header, "WORKLOAD")
+	}
+	if groupBy == GroupByContainer {
+		header = append(header, "CONTAINER")
+	}
+	header = append(header, "PODS", "CPU USE/REQ/LIM", "MEMORY USE/REQ/LIM")
+	if groupBy == GroupByContainer {
+		header = append(header, "SUGGESTED REQ (PER POD)", "FLAGS")
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		cols := []string{row.Namespace}
+		if groupBy != GroupByNamespace {
+			cols = append(cols, row.Workload)
+		}
+		if groupBy == GroupByContainer {
+			cols = append(cols, row.Container)
+		}
+		cols = append(cols,
+			fmt.Sprintf("%d", row.Pods),
+			fmt.Sprintf("%s/%s/%s", formatCPU(row.CPUUsage), formatCPU(row.CPURequest), formatCPU(row.CPULimit)),
+			fmt.Sprintf("%s/%s/%s", formatMemory(row.MemoryUsage), formatMemory(row.MemoryRequest), formatMemory(row.MemoryLimit)),
+		)
+		if groupBy == GroupByContainer {
+			cols = append(cols,
+				fmt.Sprintf("cpu=%s memory=%s", formatCPU(row.SuggestedCPU), formatMemory(row.SuggestedMemory)),
+				strings.Join(row.Flags, ","),
+			)
+		}
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	}
+	return tw.Flush()
+}
+
+func formatCPU(millicores int64) string {
+	if millicores == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dm", millicores)
+}
+
+func formatMemory(bytes int64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dMi", (bytes+(1<<20)-1)>>20)
+}
@@ -1,28 +1,38 @@
-package gitlab_update_file
+package kubeconfig_from_vault
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
 
-	"github.com/hashicorp/vault/api"
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/kubeconfig_utils"
+	"github.com/sikalabs/slr/internal/vault_utils"
 	"github.com/sikalabs/slu/pkg/utils/error_utils"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 var FlagVaultAddr string
 var FlagVaultSecretPath string
 var FlagLoginOIDC bool
+var FlagContextName string
+var FlagNamespace string
+var FlagPrint bool
 
 var Cmd = &cobra.Command{
 	Use:   "kubeconfig-from-vault",
-	Short: "Add kubeconfig from Vault to ~/.kube/config",
+	Short: "Add kubeconfig from Vault to ~/.kube/config (or $KUBECONFIG)",
+	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		kubeconfigFromVault(FlagVaultAddr, FlagVaultSecretPath)
+		kubeconfigFromVault(
+			FlagVaultAddr,
+			FlagVaultSecretPath,
+			FlagLoginOIDC,
+			FlagContextName,
+			FlagNamespace,
+			FlagPrint,
+		)
 	},
 }
 
@@ -50,80 +60,62 @@ func init() {
 		false,
 		"Vault Login with OIDC",
 	)
+	Cmd.Flags().StringVar(
+		&FlagContextName,
+		"context-name",
+		"",
+		"Context name (default KUBERNETES_CLUSTER_NAME from secret)",
+	)
+	Cmd.Flags().StringVarP(
+		&FlagNamespace,
+		"namespace",
+		"n",
+		"",
+		"Default namespace of the context (default KUBERNETES_NAMESPACE from secret)",
+	)
+	Cmd.Flags().BoolVar(
+		&FlagPrint,
+		"print",
+		false,
+		"Print standalone kubeconfig to stdout instead of merging it",
+	)
 }
 
-func kubeconfigFromVault(vaultAddr, secretPath string) {
-	data := readSecret(vaultAddr, secretPath)
-
-	// Print secret values
-	KUBERNETES_CLUSTER_NAME := data["KUBERNETES_CLUSTER_NAME"]
-	KUBERNETES_SERVER := data["KUBERNETES_SERVER"]
-	KUBERNETES_CA := data["KUBERNETES_CA"]
-	KUBERNETES_TOKEN := data["KUBERNETES_TOKEN"]
-
-	caFilePath := createTmpFile(KUBERNETES_CA)
-
-	if FlagLoginOIDC {
+func kubeconfigFromVault(
+	vaultAddr string,
+	secretPath string,
+	loginOIDC bool,
+	contextName string,
+	namespace string,
+	print bool,
+) {
+	if loginOIDC {
 		sh([]string{"vault", "login", "-address", vaultAddr, "-method=oidc"})
 	}
 
-	sh([]string{"kubectl", "config", "set-cluster", KUBERNETES_CLUSTER_NAME, "--server=" + KUBERNETES_SERVER, "--certificate-authority=" + caFilePath, "--embed-certs=true"})
-	sh([]string{"kubectl", "config", "set-credentials", KUBERNETES_CLUSTER_NAME, "--token=" + KUBERNETES_TOKEN})
-	sh([]string{"kubectl", "config", "set-context", KUBERNETES_CLUSTER_NAME, "--cluster=" + KUBERNETES_CLUSTER_NAME, "--user=" + KUBERNETES_CLUSTER_NAME})
-	sh([]string{"kubectl", "config", "use-context", KUBERNETES_CLUSTER_NAME})
-
-	os.Remove(caFilePath)
-}
-
-func readSecret(vaultAddr, secretPath string) map[string]string {
-	// Initialize Vault client
-	client, err := api.NewClient(&api.Config{
-		Address: vaultAddr,
-	})
+	client, err := vault_utils.NewClient(vaultAddr, "")
 	error_utils.HandleError(err)
 
-	client.SetToken(getTokenFromFile())
-
-	// Read the secret
-	secret, err := client.Logical().Read(secretPathToKV2Path(secretPath))
+	data, err := vault_utils.ReadKV2(client, secretPath)
 	error_utils.HandleError(err)
 
-	// Check if secret data exists
-	if secret == nil || secret.Data == nil {
-		error_utils.HandleError(fmt.Errorf("secret not found at path: %s", secretPathToKV2Path(secretPath)))
-	}
-
-	// Vault v2 secrets engine stores data in a nested `data` key
-	data, ok := secret.Data["data"].(map[string]interface{})
-	if !ok {
-		error_utils.HandleError(fmt.Errorf("unexpected secret format: %v", secret.Data))
-	}
-
-	output := make(map[string]string)
-	for key, value := range data {
-		output[key] = value.(string)
-	}
-	return output
-}
-
-func getTokenFromFile() string {
-	homeDir, err := os.UserHomeDir()
+	config, err := kubeconfig_utils.FromVaultData(data, contextName, namespace)
 	error_utils.HandleError(err)
 
-	tokenPath := filepath.Join(homeDir, ".vault-token")
-
-	// Read token file
-	token, err := os.ReadFile(tokenPath)
-	if err != nil {
+	if print {
+		out, err := clientcmd.Write(*config)
 		error_utils.HandleError(err)
+		fmt.Print(string(out))
+		return
 	}
 
-	return string(token)
-}
+	backup, err := kubeconfig_utils.Merge(config, config.CurrentContext)
+	error_utils.HandleError(err)
 
-func secretPathToKV2Path(secretPath string) string {
-	s := strings.Split(secretPath, "/")
-	return fmt.Sprintf("%s/data/%s", s[0], strings.Join(s[1:], "/"))
+	if backup != "" {
+		fmt.Fprintf(os.Stderr, "Previous kubeconfig backed up to %s\n", backup)
+	}
+	fmt.Fprintf(os.Stderr, "Context %s added to %s and set as current\n", config.CurrentContext, kubeconfig_utils.Path())
 }
 
 func sh(command []string) {
@@ -133,18 +125,3 @@ func sh(command []string) {
 	err := cmd.Run()
 	error_utils.HandleError(err)
 }
-
-func createTmpFile(data string) string {
-	decodedData, err := base64.StdEncoding.DecodeString(data)
-	error_utils.HandleError(err)
-
-	tmpFile, err := os.CreateTemp("", "ca-*.crt")
-	error_utils.HandleError(err)
-
-	tmpFilePath := tmpFile.Name()
-	_, err = tmpFile.Write(decodedData)
-	error_utils.HandleError(err)
-	tmpFile.Close()
-
-	return tmpFilePath
-}
@@ -0,0 +1,79 @@
+package kubeconfig_to_vault
+
+import (
+	"fmt"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/kubeconfig_utils"
+	"github.com/sikalabs/slr/internal/vault_utils"
+	"github.com/sikalabs/slu/pkg/utils/error_utils"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var FlagVaultAddr string
+var FlagVaultSecretPath string
+var FlagContext string
+
+var Cmd = &cobra.Command{
+	Use:   "kubeconfig-to-vault",
+	Short: "Upload context from kubeconfig to Vault (schema of kubeconfig-from-vault)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		kubeconfigToVault(FlagVaultAddr, FlagVaultSecretPath, FlagContext)
+	},
+}
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(
+		&FlagVaultAddr,
+		"vault-addr",
+		"a",
+		"",
+		"Vault Address",
+	)
+	Cmd.MarkFlagRequired("vault-addr")
+	Cmd.Flags().StringVarP(
+		&FlagVaultSecretPath,
+		"path",
+		"p",
+		"",
+		"Vault Secret Path",
+	)
+	Cmd.MarkFlagRequired("path")
+	Cmd.Flags().StringVar(
+		&FlagContext,
+		"context",
+		"",
+		"Kubeconfig context to upload (default current context)",
+	)
+}
+
+func kubeconfigToVault(vaultAddr, secretPath, contextName string) {
+	config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	error_utils.HandleError(err)
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	if contextName == "" {
+		error_utils.HandleError(fmt.Errorf("no current context, use --context"))
+	}
+
+	// Embed CA and client certificates referenced by path
+	err = clientcmdapi.FlattenConfig(config)
+	error_utils.HandleError(err)
+
+	data, err := kubeconfig_utils.ToVaultData(config, contextName)
+	error_utils.HandleError(err)
+
+	client, err := vault_utils.NewClient(vaultAddr, "")
+	error_utils.HandleError(err)
+
+	err = vault_utils.WriteKV2(client, secretPath, data)
+	error_utils.HandleError(err)
+
+	fmt.Printf("Context %s uploaded to Vault: %s\n", contextName, secretPath)
+}
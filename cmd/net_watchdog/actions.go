@@ -0,0 +1,137 @@
+package net_watchdog
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	ActionIPLink   = "ip-link"
+	ActionNmcli    = "nmcli"
+	ActionIfupdown = "ifupdown"
+	ActionSystemd  = "systemd"
+	ActionCommand  = "command"
+	ActionReboot   = "reboot"
+)
+
+// Action is a step of the escalation ladder, <type>[:<target>]:
+//
+//	ip-link:eno1            ip link set eno1 down && ip link set eno1 up
+//	nmcli:eno1              nmcli device disconnect eno1 && nmcli device connect eno1
+//	ifupdown:eno1           ifdown eno1 && ifup eno1
+//	systemd:NetworkManager  systemctl restart NetworkManager
+//	command:<shell>         sh -c <shell>
+//	reboot                  systemctl reboot (only after --reboot-after failures)
+type Action struct {
+	Type   string
+	Target string
+}
+
+func (a Action) String() string {
+	if a.Target == "" {
+		return a.Type
+	}
+	return a.Type + ":" + a.Target
+}
+
+// Interface is the restarted network interface, empty for other actions
+func (a Action) Interface() string {
+	switch a.Type {
+	case ActionIPLink, ActionNmcli, ActionIfupdown:
+		return a.Target
+	}
+	return ""
+}
+
+func ParseAction(s string) (Action, error) {
+	typ, target, _ := strings.Cut(s, ":")
+	switch typ {
+	case ActionIPLink, ActionNmcli, ActionIfupdown, ActionSystemd, ActionCommand:
+		if target == "" {
+			return Action{}, fmt.Errorf("action %s requires target (%s:<target>)", typ, typ)
+		}
+	case ActionReboot:
+		if target != "" {
+			return Action{}, fmt.Errorf("action reboot has no target")
+		}
+	default:
+		return Action{}, fmt.Errorf("unknown action type %q (ip-link, nmcli, ifupdown, systemd, command, reboot)", typ)
+	}
+	return Action{Type: typ, Target: target}, nil
+}
+
+// commands returns commands of the action run one after another with a
+// pause between them
+func (a Action) commands() [][]string {
+	switch a.Type {
+	case ActionIPLink:
+		return [][]string{
+			{"ip", "link", "set", a.Target, "down"},
+			{"ip", "link", "set", a.Target, "up"},
+		}
+	case ActionNmcli:
+		return [][]string{
+			{"nmcli", "device", "disconnect", a.Target},
+			{"nmcli", "device", "connect", a.Target},
+		}
+	case ActionIfupdown:
+		return [][]string{
+			{"ifdown", a.Target},
+			{"ifup", a.Target},
+		}
+	case ActionSystemd:
+		return [][]string{{"systemctl", "restart", a.Target}}
+	case ActionCommand:
+		return [][]string{{"sh", "-c", a.Target}}
+	case ActionReboot:
+		return [][]string{{"systemctl", "reboot"}}
+	}
+	return nil
+}
+
+// run returns combined output of all commands. Commands of ifupdown
+// action are logged as ifdown and ifup events, as restart-eno1 always did.
+func (a Action) run(sudo bool, logFile string) (string, error) {
+	outputs := []string{}
+	for i, command := range a.commands() {
+		if i > 0 {
+			time.Sleep(2 * time.Second)
+		}
+		event := ""
+		if a.Type == ActionIfupdown {
+			event = command[0]
+		}
+		if sudo {
+			command = append([]string{"sudo"}, command...)
+		}
+		fmt.Printf("Running %s...\n", strings.Join(command, " "))
+		out, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+		output := strings.TrimSpace(string(out))
+		if output != "" {
+			outputs = append(outputs, output)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s failed: %v", strings.Join(command, " "), err)
+			if event != "" {
+				writeLog(logFile, LogEntry{
+					Event:     event,
+					Status:    StatusERR,
+					Interface: a.Target,
+					Message:   fmt.Sprintf("%v, output: %s", err, output),
+				})
+			}
+			return strings.Join(outputs, "\n"), err
+		}
+		if event != "" {
+			writeLog(logFile, LogEntry{
+				Event:     event,
+				Status:    StatusOK,
+				Interface: a.Target,
+				Message:   output,
+			})
+		}
+	}
+	return strings.Join(outputs, "\n"), nil
+}
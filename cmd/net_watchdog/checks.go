@@ -0,0 +1,196 @@
+package net_watchdog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CheckHTTP = "http"
+	CheckDNS  = "dns"
+	CheckPing = "ping"
+	CheckTCP  = "tcp"
+)
+
+// Check is <type>:<target>:
+//
+//	http:https://checkip.amazonaws.com/  GET returns 2xx
+//	dns:example.com[@1.1.1.1]            name resolves (optionally using the server)
+//	ping:gateway                         ICMP echo, "gateway" is the default gateway
+//	tcp:1.1.1.1:53                       TCP connect
+type Check struct {
+	Type   string
+	Target string
+}
+
+func (c Check) String() string {
+	return c.Type + ":" + c.Target
+}
+
+func ParseCheck(s string) (Check, error) {
+	typ, target, ok := strings.Cut(s, ":")
+	if !ok || target == "" {
+		return Check{}, fmt.Errorf("invalid check %q, expected <type>:<target>", s)
+	}
+	switch typ {
+	case CheckHTTP:
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return Check{}, fmt.Errorf("invalid check %q, expected http:<url>", s)
+		}
+	case CheckDNS, CheckPing:
+	case CheckTCP:
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return Check{}, fmt.Errorf("invalid check %q, expected tcp:<host>:<port>", s)
+		}
+	default:
+		return Check{}, fmt.Errorf("unknown check type %q (http, dns, ping, tcp)", typ)
+	}
+	return Check{Type: typ, Target: target}, nil
+}
+
+// runChecks runs all checks concurrently, with requireAll all of them
+// have to pass, otherwise any of them
+func runChecks(checks []Check, requireAll bool, timeout time.Duration, logFile string) bool {
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.run(timeout)
+		}()
+	}
+	wg.Wait()
+
+	passed := 0
+	for i, check := range checks {
+		entry := LogEntry{Event: "network_check", Check: check.String()}
+		if err := results[i]; err != nil {
+			fmt.Printf("Check %s failed: %v\n", check, err)
+			entry.Status = StatusERR
+			entry.Message = err.Error()
+		} else {
+			fmt.Printf("Check %s OK\n", check)
+			entry.Status = StatusOK
+			passed++
+		}
+		writeLog(logFile, entry)
+	}
+	if requireAll {
+		return passed == len(checks)
+	}
+	return passed > 0
+}
+
+func (c Check) run(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch c.Type {
+	case CheckHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("status code %d", resp.StatusCode)
+		}
+		return nil
+
+	case CheckDNS:
+		host, server, _ := strings.Cut(c.Target, "@")
+		resolver := net.DefaultResolver
+		if server != "" {
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(server, "53")
+			}
+			resolver = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, server)
+				},
+			}
+		}
+		addrs, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no addresses")
+		}
+		return nil
+
+	case CheckPing:
+		target := c.Target
+		if target == "gateway" {
+			gateway, err := defaultGateway()
+			if err != nil {
+				return err
+			}
+			target = gateway
+		}
+		seconds := strconv.Itoa(int(math.Ceil(max(timeout, time.Second).Seconds())))
+		out, err := exec.CommandContext(ctx, "ping", "-c", "1", "-W", seconds, target).CombinedOutput()
+		if err != nil {
+			if line := lastLine(out); line != "" {
+				return fmt.Errorf("ping %s: %v: %s", target, err, line)
+			}
+			return fmt.Errorf("ping %s: %v", target, err)
+		}
+		return nil
+
+	case CheckTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", c.Target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return fmt.Errorf("unknown check type %q", c.Type)
+}
+
+// defaultGateway reads IPv4 default gateway from /proc/net/route
+func defaultGateway() (string, error) {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		// Iface Destination Gateway ...
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("no default gateway")
+}
+
+func lastLine(out []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return lines[len(lines)-1]
+}
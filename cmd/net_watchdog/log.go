@@ -0,0 +1,55 @@
+package net_watchdog
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	StatusOK  = "OK"
+	StatusERR = "ERR"
+)
+
+// LogEntry is one line of the JSON log (--log-file). Escalation steps
+// have Step (1-based index in the ladder), Action and Outcome of the
+// action itself ("ok", "failed", "skipped") or of the verification after
+// it ("recovered", "not_recovered").
+type LogEntry struct {
+	Date      string `json:"date"`
+	Event     string `json:"event"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Check     string `json:"check,omitempty"`
+	Step      int    `json:"step,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Outcome   string `json:"outcome,omitempty"`
+	Failures  int    `json:"failures,omitempty"`
+}
+
+func writeLog(logFile string, entry LogEntry) {
+	if logFile == "" {
+		return
+	}
+
+	entry.Date = time.Now().Format(time.RFC3339)
+
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to marshal log entry: %v", err)
+		return
+	}
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to open log file: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(string(jsonData) + "\n"); err != nil {
+		log.Printf("Failed to write to log file: %v", err)
+	}
+}
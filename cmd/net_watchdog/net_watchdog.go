@@ -0,0 +1,142 @@
+package net_watchdog
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/spf13/cobra"
+)
+
+var FlagChecks []string
+var FlagRequire string
+var FlagActions []string
+var FlagTimeout time.Duration
+var FlagSettle time.Duration
+var FlagRebootAfter int
+var FlagInterval time.Duration
+var FlagMaxBackoff time.Duration
+var FlagMaxRestartsPerHour int
+var FlagOnce bool
+var FlagSudo bool
+var FlagStateFile string
+var FlagLogFile string
+var FlagTelegramBotToken string
+var FlagTelegramChatID string
+var FlagTelegramTimeout time.Duration
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringArrayVar(&FlagChecks, "check", nil, "Check as <type>:<target> (http, dns, ping, tcp), can be repeated")
+	Cmd.Flags().StringVar(&FlagRequire, "require", "any", "Network is up if any or all checks pass (any, all)")
+	Cmd.Flags().StringArrayVar(&FlagActions, "action", nil, "Escalation step as <type>[:<target>] (ip-link, nmcli, ifupdown, systemd, command, reboot), can be repeated")
+	Cmd.Flags().DurationVarP(&FlagTimeout, "timeout", "t", 10*time.Second, "Timeout of each check")
+	Cmd.Flags().DurationVar(&FlagSettle, "settle", 10*time.Second, "Wait after each action before verifying recovery")
+	Cmd.Flags().IntVar(&FlagRebootAfter, "reboot-after", 3, "Consecutive failures before the reboot action runs")
+	Cmd.Flags().DurationVar(&FlagInterval, "interval", time.Minute, "Interval between checks")
+	Cmd.Flags().DurationVar(&FlagMaxBackoff, "max-backoff", 30*time.Minute, "Maximum wait before the next escalation, it's doubled after every cycle without recovery")
+	Cmd.Flags().IntVar(&FlagMaxRestartsPerHour, "max-restarts-per-hour", 5, "Escalations per hour after which the network is flapping, escalation pauses and alert is sent (0 for unlimited)")
+	Cmd.Flags().BoolVar(&FlagOnce, "once", false, "Run a single cycle and exit (non-zero if the network stays down)")
+	Cmd.Flags().BoolVar(&FlagSudo, "sudo", false, "Run actions with sudo")
+	Cmd.Flags().StringVar(&FlagStateFile, "state-file", "", "JSON file with failures, restarts and queued notifications kept between runs")
+	Cmd.Flags().StringVarP(&FlagLogFile, "log-file", "l", "", "Log file path for JSON logs")
+	Cmd.Flags().StringVar(&FlagTelegramBotToken, "bot-token", "", "Telegram bot token for notifications")
+	Cmd.Flags().StringVar(&FlagTelegramChatID, "chat-id", "", "Telegram chat ID for notifications")
+	Cmd.Flags().DurationVar(&FlagTelegramTimeout, "telegram-timeout", 30*time.Second, "Maximum time spent sending queued notifications per cycle, the rest is retried in the next one")
+	Cmd.MarkFlagRequired("check")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "net-watchdog",
+	Short: "Check network connectivity and escalate recovery actions when it's down",
+	Long: `Check network connectivity and escalate recovery actions when it's down.
+
+When checks fail, actions run one after another, after each of them the
+checks are run again and the ladder stops when the network recovered.
+Reboot runs only after --reboot-after consecutive failed cycles. Wait
+before the next escalation is doubled after every cycle without recovery
+up to --max-backoff. After --max-restarts-per-hour escalations the network
+is flapping, escalation pauses and a single alert is sent.
+
+Telegram notifications are queued and sent without blocking the checks,
+unsent ones are retried in later cycles. With --once (systemd timer)
+failures, restarts and the queue are kept in --state-file.
+
+Checks:
+  http:<url>                 GET returns 2xx
+  dns:<name>[@<server>]      name resolves
+  ping:<host>|gateway        ICMP echo (gateway is the default gateway)
+  tcp:<host>:<port>          TCP connect
+
+Actions:
+  ip-link:<interface>        ip link set down/up
+  nmcli:<interface>          NetworkManager device disconnect/connect
+  ifupdown:<interface>       ifdown/ifup
+  systemd:<unit>             systemctl restart <unit>
+  command:<shell command>    sh -c <shell command>
+  reboot                     systemctl reboot`,
+	Example: `  slr net-watchdog \
+    --check http:https://checkip.amazonaws.com/ \
+    --check dns:example.com@1.1.1.1 \
+    --check ping:gateway \
+    --action ip-link:eno1 \
+    --action systemd:NetworkManager \
+    --action reboot --reboot-after 5 \
+    --log-file /var/log/net-watchdog.log`,
+	Args: cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		opts, err := optionsFromFlags()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := Run(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func optionsFromFlags() (Options, error) {
+	opts := Options{
+		Timeout:            FlagTimeout,
+		Settle:             FlagSettle,
+		RebootAfter:        FlagRebootAfter,
+		Interval:           FlagInterval,
+		MaxBackoff:         FlagMaxBackoff,
+		MaxRestartsPerHour: FlagMaxRestartsPerHour,
+		Once:               FlagOnce,
+		Sudo:               FlagSudo,
+		StateFile:          FlagStateFile,
+		LogFile:            FlagLogFile,
+		TelegramBotToken:   FlagTelegramBotToken,
+		TelegramChatID:     FlagTelegramChatID,
+		TelegramTimeout:    FlagTelegramTimeout,
+	}
+	switch FlagRequire {
+	case "any":
+	case "all":
+		opts.RequireAll = true
+	default:
+		return opts, fmt.Errorf("unknown --require %q (any, all)", FlagRequire)
+	}
+	for _, s := range FlagChecks {
+		check, err := ParseCheck(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Checks = append(opts.Checks, check)
+	}
+	for _, s := range FlagActions {
+		action, err := ParseAction(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Actions = append(opts.Actions, action)
+	}
+	if opts.Interval <= 0 {
+		return opts, fmt.Errorf("--interval must be positive")
+	}
+	return opts, nil
+}
@@ -0,0 +1,90 @@
+package net_watchdog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sikalabs/slr/internal/file_utils"
+)
+
+// notificationMaxAge is age of queued notifications after which they are
+// dropped
+const notificationMaxAge = 24 * time.Hour
+
+// State is kept between runs in --state-file (in memory without it)
+type State struct {
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// LastRestart is the last run of the escalation ladder
+	LastRestart *time.Time `json:"lastRestart,omitempty"`
+	// Restarts are runs of the escalation ladder in the last hour
+	Restarts []time.Time `json:"restarts"`
+	// NextAttempt is the earliest next run of the ladder (backoff)
+	NextAttempt *time.Time `json:"nextAttempt,omitempty"`
+	// Flapping is set when the flapping alert was sent
+	Flapping      bool           `json:"flapping"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// Notification is a queued Telegram message, sent on this or later runs
+type Notification struct {
+	Message   string    `json:"message"`
+	Created   time.Time `json:"created"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+func loadState(path string) (*State, error) {
+	state := &State{}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (s *State) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return file_utils.WriteFileAtomic(path, data, 0600, "", "")
+}
+
+// restartsInLastHour drops older restarts and returns the rest
+func (s *State) restartsInLastHour(now time.Time) int {
+	restarts := []time.Time{}
+	for _, t := range s.Restarts {
+		if now.Sub(t) < time.Hour {
+			restarts = append(restarts, t)
+		}
+	}
+	s.Restarts = restarts
+	return len(restarts)
+}
+
+func (s *State) recordRestart(now time.Time) {
+	s.LastRestart = &now
+	s.Restarts = append(s.Restarts, now)
+}
+
+func (s *State) queue(message string) {
+	s.Notifications = append(s.Notifications, Notification{Message: message, Created: time.Now()})
+}
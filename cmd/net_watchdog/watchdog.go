@@ -0,0 +1,298 @@
+package net_watchdog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sikalabs/slu/utils/telegram_utils"
+)
+
+// Options of the watchdog, restart-eno1 runs it with its flags
+type Options struct {
+	Checks     []Check
+	RequireAll bool
+	Actions    []Action
+	// Timeout of each check
+	Timeout time.Duration
+	// Settle is wait after an action before the checks are run again
+	Settle time.Duration
+	// RebootAfter is number of consecutive failed cycles before reboot
+	// action is run
+	RebootAfter int
+	// Interval between cycles, the escalation ladder is retried after
+	// Interval doubled for every failed cycle up to MaxBackoff
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	// MaxRestartsPerHour are runs of the ladder after which the network
+	// is considered flapping, the ladder is paused and alert is sent
+	// (0 for unlimited)
+	MaxRestartsPerHour int
+	// Once runs a single cycle (for systemd timers)
+	Once bool
+	Sudo bool
+
+	// StateFile keeps State between runs
+	StateFile        string
+	LogFile          string
+	TelegramBotToken string
+	TelegramChatID   string
+	// TelegramTimeout limits time spent sending queued notifications in
+	// one cycle, unsent ones are retried in the next cycle
+	TelegramTimeout time.Duration
+}
+
+type watchdog struct {
+	opts  Options
+	state *State
+}
+
+// Run runs cycles until the process is stopped, with Once it returns
+// error if the network is down after the cycle
+func Run(opts Options) error {
+	if len(opts.Checks) == 0 {
+		return fmt.Errorf("no checks")
+	}
+	state, err := loadState(opts.StateFile)
+	if err != nil {
+		return err
+	}
+	w := &watchdog{opts: opts, state: state}
+	for {
+		up := w.cycle()
+		w.sendNotifications()
+		if err := w.state.save(opts.StateFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save state: %v\n", err)
+		}
+		if opts.Once {
+			if !up {
+				return fmt.Errorf("network is not reachable")
+			}
+			return nil
+		}
+		time.Sleep(opts.Interval)
+	}
+}
+
+// backoff is wait before the next run of the ladder, Interval doubled for
+// every consecutive failure
+func (w *watchdog) backoff() time.Duration {
+	d := w.opts.Interval
+	for i := 1; i < w.state.ConsecutiveFailures && (w.opts.MaxBackoff == 0 || d < w.opts.MaxBackoff); i++ {
+		d *= 2
+	}
+	if w.opts.MaxBackoff > 0 {
+		d = min(d, w.opts.MaxBackoff)
+	}
+	return d
+}
+
+// cycle checks the network and runs the escalation ladder until the
+// network recovers, it returns if the network is up at the end
+func (w *watchdog) cycle() bool {
+	o := w.opts
+	s := w.state
+	now := time.Now()
+	restarts := s.restartsInLastHour(now)
+
+	if runChecks(o.Checks, o.RequireAll, o.Timeout, o.LogFile) {
+		if s.ConsecutiveFailures > 0 {
+			fmt.Println("Network is reachable again")
+		} else {
+			fmt.Println("Network is reachable")
+		}
+		s.ConsecutiveFailures = 0
+		s.NextAttempt = nil
+		if s.Flapping && (o.MaxRestartsPerHour == 0 || restarts < o.MaxRestartsPerHour) {
+			s.Flapping = false
+			writeLog(o.LogFile, LogEntry{Event: "flapping", Status: StatusOK, Message: "Network is stable again"})
+		}
+		return true
+	}
+	s.ConsecutiveFailures++
+	fmt.Printf("\nNetwork is not reachable (%d consecutive failures)\n\n", s.ConsecutiveFailures)
+
+	if o.MaxRestartsPerHour > 0 && restarts >= o.MaxRestartsPerHour {
+		resume := s.Restarts[0].Add(time.Hour)
+		message := fmt.Sprintf("Network is flapping, %d restarts in the last hour, paused until %s", restarts, resume.Format("15:04"))
+		fmt.Println(message)
+		writeLog(o.LogFile, LogEntry{Event: "flapping", Status: StatusERR, Message: message, Failures: s.ConsecutiveFailures})
+		if !s.Flapping {
+			s.Flapping = true
+			s.queue("🔁 " + message)
+		}
+		return false
+	}
+	if s.NextAttempt != nil && now.Before(*s.NextAttempt) {
+		message := fmt.Sprintf("Backoff, next restart at %s", s.NextAttempt.Format("15:04:05"))
+		fmt.Println(message)
+		writeLog(o.LogFile, LogEntry{Event: "backoff", Status: StatusERR, Message: message, Failures: s.ConsecutiveFailures})
+		return false
+	}
+
+	if w.escalate() {
+		s.ConsecutiveFailures = 0
+		s.NextAttempt = nil
+		return true
+	}
+	next := time.Now().Add(w.backoff())
+	s.NextAttempt = &next
+	return false
+}
+
+// escalate runs the actions until the network recovers
+func (w *watchdog) escalate() bool {
+	o := w.opts
+	s := w.state
+	// one run of the ladder is one restart
+	recorded := false
+	record := func() {
+		if !recorded {
+			s.recordRestart(time.Now())
+			recorded = true
+		}
+	}
+
+	for i, action := range o.Actions {
+		step := LogEntry{
+			Event:     "action",
+			Interface: action.Interface(),
+			Step:      i + 1,
+			Action:    action.String(),
+			Failures:  s.ConsecutiveFailures,
+		}
+
+		if action.Type == ActionReboot && s.ConsecutiveFailures < o.RebootAfter {
+			fmt.Printf("Step %d %s skipped (%d of %d failures)\n", i+1, action, s.ConsecutiveFailures, o.RebootAfter)
+			step.Status = StatusOK
+			step.Outcome = "skipped"
+			step.Message = fmt.Sprintf("%d of %d failures required for reboot", s.ConsecutiveFailures, o.RebootAfter)
+			writeLog(o.LogFile, step)
+			continue
+		}
+		if action.Type == ActionReboot {
+			// queued message is sent after the reboot
+			s.queue(fmt.Sprintf("⚠️ Network was not reachable after %d checks, rebooted", s.ConsecutiveFailures))
+			record()
+			if err := s.save(o.StateFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to save state: %v\n", err)
+			}
+		}
+
+		fmt.Printf("Step %d: %s\n", i+1, action)
+		record()
+		output, err := action.run(o.Sudo, o.LogFile)
+		step.Message = output
+		if err != nil {
+			fmt.Printf("Step %d %s failed: %v\n", i+1, action, err)
+			step.Status = StatusERR
+			step.Outcome = "failed"
+			step.Message = fmt.Sprintf("%v, output: %s", err, output)
+			writeLog(o.LogFile, step)
+			continue
+		}
+		step.Status = StatusOK
+		step.Outcome = "ok"
+		writeLog(o.LogFile, step)
+
+		time.Sleep(o.Settle)
+		verify := LogEntry{
+			Event:     "verify",
+			Interface: action.Interface(),
+			Step:      i + 1,
+			Action:    action.String(),
+			Failures:  s.ConsecutiveFailures,
+		}
+		if runChecks(o.Checks, o.RequireAll, o.Timeout, o.LogFile) {
+			fmt.Printf("\nNetwork recovered after step %d %s\n", i+1, action)
+			verify.Status = StatusOK
+			verify.Outcome = "recovered"
+			writeLog(o.LogFile, verify)
+			s.queue(fmt.Sprintf("⚠️ Network was not reachable, recovered by %s", action))
+			return true
+		}
+		fmt.Printf("Network not recovered after step %d %s\n\n", i+1, action)
+		verify.Status = StatusERR
+		verify.Outcome = "not_recovered"
+		writeLog(o.LogFile, verify)
+	}
+
+	if len(o.Actions) > 0 {
+		s.queue(fmt.Sprintf("🚨 Network is not reachable, %d actions didn't help (%d consecutive failures)", len(o.Actions), s.ConsecutiveFailures))
+	}
+	return false
+}
+
+// sendNotifications sends queued notifications in order, it stops on the
+// first error (the network is likely down) or after TelegramTimeout and
+// keeps the rest for the next cycle
+func (w *watchdog) sendNotifications() {
+	o := w.opts
+	s := w.state
+	if o.TelegramBotToken == "" || o.TelegramChatID == "" {
+		s.Notifications = nil
+		return
+	}
+	if len(s.Notifications) == 0 {
+		return
+	}
+
+	chatID, err := strconv.ParseInt(o.TelegramChatID, 10, 64)
+	if err != nil {
+		fmt.Printf("Invalid chat ID format: %v\n", err)
+		writeLog(o.LogFile, LogEntry{
+			Event:   "telegram_notification",
+			Status:  StatusERR,
+			Message: fmt.Sprintf("Invalid chat ID format: %v", err),
+		})
+		return
+	}
+
+	deadline := time.Now().Add(o.TelegramTimeout)
+	for len(s.Notifications) > 0 && time.Now().Before(deadline) {
+		n := &s.Notifications[0]
+		if time.Since(n.Created) > notificationMaxAge {
+			writeLog(o.LogFile, LogEntry{
+				Event:   "telegram_notification",
+				Status:  StatusERR,
+				Message: fmt.Sprintf("Dropped notification after %d attempts: %s", n.Attempts, n.Message),
+			})
+			s.Notifications = s.Notifications[1:]
+			continue
+		}
+
+		n.Attempts++
+		fmt.Printf("Sending Telegram notification to chat %s (attempt %d)...\n", o.TelegramChatID, n.Attempts)
+		// TelegramSendMessage has no timeout, don't wait for it longer
+		// than the deadline, the buffered channel lets the goroutine
+		// finish even if we stop waiting
+		result := make(chan error, 1)
+		go func(message string) {
+			result <- telegram_utils.TelegramSendMessage(o.TelegramBotToken, chatID, message)
+		}(n.Message)
+		var err error
+		select {
+		case err = <-result:
+		case <-time.After(time.Until(deadline)):
+			err = fmt.Errorf("timeout after %s", o.TelegramTimeout)
+		}
+		if err != nil {
+			fmt.Printf("Failed to send Telegram notification: %v\n", err)
+			n.LastError = err.Error()
+			writeLog(o.LogFile, LogEntry{
+				Event:   "telegram_notification",
+				Status:  StatusERR,
+				Message: fmt.Sprintf("Failed to send Telegram notification (attempt %d, %d queued): %v", n.Attempts, len(s.Notifications), err),
+			})
+			return
+		}
+		fmt.Println("Telegram notification sent successfully!")
+		writeLog(o.LogFile, LogEntry{
+			Event:   "telegram_notification",
+			Status:  StatusOK,
+			Message: "Telegram notification sent successfully",
+		})
+		s.Notifications = s.Notifications[1:]
+	}
+}
@@ -0,0 +1,155 @@
+package scan_network
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// pinger sends echo requests over one shared ICMP socket, replies are
+// matched to waiting pings by source address and sequence number
+type pinger struct {
+	conn       *icmp.PacketConn
+	ipv6       bool
+	privileged bool
+	id         int
+	seq        atomic.Uint32
+
+	mu      sync.Mutex
+	waiting map[pingKey]chan struct{}
+}
+
+type pingKey struct {
+	addr netip.Addr
+	seq  int
+}
+
+// newPinger opens unprivileged ping socket (udp4, udp6) or raw socket if
+// the unprivileged one is not allowed
+func newPinger(ipv6 bool) (*pinger, error) {
+	networks := [][2]string{{"udp4", "0.0.0.0"}, {"ip4:icmp", "0.0.0.0"}}
+	if ipv6 {
+		networks = [][2]string{{"udp6", "::"}, {"ip6:ipv6-icmp", "::"}}
+	}
+	var conn *icmp.PacketConn
+	var err error
+	privileged := false
+	for i, n := range networks {
+		conn, err = icmp.ListenPacket(n[0], n[1])
+		if err == nil {
+			privileged = i == 1
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pinger{
+		conn:       conn,
+		ipv6:       ipv6,
+		privileged: privileged,
+		id:         os.Getpid() & 0xffff,
+		waiting:    map[pingKey]chan struct{}{},
+	}
+	go p.receive()
+	return p, nil
+}
+
+func (p *pinger) Close() {
+	p.conn.Close()
+}
+
+func (p *pinger) Ping(addr netip.Addr, timeout time.Duration) (time.Duration, bool) {
+	key := pingKey{addr: addr.WithZone(""), seq: int(p.seq.Add(1) & 0xffff)}
+	reply := make(chan struct{})
+	p.mu.Lock()
+	p.waiting[key] = reply
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiting, key)
+		p.mu.Unlock()
+	}()
+
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if p.ipv6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: p.id, Seq: key.seq, Data: []byte("slr scan-network")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, false
+	}
+	var dst net.Addr = &net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	if !p.privileged {
+		dst = &net.UDPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	}
+
+	start := time.Now()
+	if _, err := p.conn.WriteTo(b, dst); err != nil {
+		return 0, false
+	}
+	select {
+	case <-reply:
+		return time.Since(start), true
+	case <-time.After(timeout):
+		return 0, false
+	}
+}
+
+func (p *pinger) receive() {
+	proto := protocolICMP
+	if p.ipv6 {
+		proto = protocolIPv6ICMP
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			// closed
+			return
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || (msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply) {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		// kernel sets ID of unprivileged sockets, raw sockets receive
+		// replies to other processes too
+		if !ok || (p.privileged && echo.ID != p.id) {
+			continue
+		}
+
+		var ip net.IP
+		switch a := peer.(type) {
+		case *net.UDPAddr:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		key := pingKey{addr: addr.Unmap(), seq: echo.Seq}
+		p.mu.Lock()
+		if reply, ok := p.waiting[key]; ok {
+			close(reply)
+			delete(p.waiting, key)
+		}
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,57 @@
+package scan_network
+
+import (
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// neighbors returns MAC addresses from the ARP/neighbor table, read after
+// the scan which fills it (only for hosts on local networks)
+func neighbors() map[netip.Addr]string {
+	macs := map[netip.Addr]string{}
+
+	// Linux: 192.168.1.1 dev eth0 lladdr aa:bb:cc:dd:ee:ff REACHABLE
+	if out, err := exec.Command("ip", "neigh", "show").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			for i := 0; i+1 < len(fields); i++ {
+				if fields[i] == "lladdr" {
+					addMAC(macs, fields[0], fields[i+1])
+				}
+			}
+		}
+		return macs
+	}
+
+	// Linux without iproute2
+	if data, err := os.ReadFile("/proc/net/arp"); err == nil {
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 {
+				addMAC(macs, fields[0], fields[3])
+			}
+		}
+		return macs
+	}
+
+	// macOS, BSD: ? (192.168.1.1) at aa:bb:cc:dd:ee:ff on en0 ...
+	if out, err := exec.Command("arp", "-an").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[2] == "at" {
+				addMAC(macs, strings.Trim(fields[1], "()"), fields[3])
+			}
+		}
+	}
+	return macs
+}
+
+func addMAC(macs map[netip.Addr]string, ip, mac string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || mac == "00:00:00:00:00:00" || !strings.Contains(mac, ":") {
+		return
+	}
+	macs[addr.WithZone("").Unmap()] = mac
+}
@@ -0,0 +1,121 @@
+package scan_network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// prober probes one host, it returns nil if the host is down
+type prober struct {
+	ports   []int
+	timeout time.Duration
+
+	once4, once6     sync.Once
+	pinger4, pinger6 *pinger
+}
+
+func newProber(ports []int, timeout time.Duration) *prober {
+	return &prober{ports: ports, timeout: timeout}
+}
+
+func (p *prober) Probe(addr netip.Addr) *Result {
+	if len(p.ports) > 0 {
+		return p.probeTCP(addr)
+	}
+	return p.probeICMP(addr)
+}
+
+func (p *prober) Close() {
+	if p.pinger4 != nil {
+		p.pinger4.Close()
+	}
+	if p.pinger6 != nil {
+		p.pinger6.Close()
+	}
+}
+
+// probeTCP connects to all ports, host is up if any port is open or
+// refuses the connection
+func (p *prober) probeTCP(addr netip.Addr) *Result {
+	result := &Result{IP: addr.String(), addr: addr, OpenPorts: []int{}}
+	up := false
+	rtt := time.Duration(math.MaxInt64)
+	for _, port := range p.ports {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr.String(), strconv.Itoa(port)), p.timeout)
+		elapsed := time.Since(start)
+		switch {
+		case err == nil:
+			conn.Close()
+			result.OpenPorts = append(result.OpenPorts, port)
+		case errors.Is(err, syscall.ECONNREFUSED):
+		default:
+			continue
+		}
+		up = true
+		rtt = min(rtt, elapsed)
+	}
+	if !up {
+		return nil
+	}
+	result.RTT = milliseconds(rtt)
+	return result
+}
+
+// probeICMP uses ICMP socket of the address family, or the ping command
+// if the socket can't be opened
+func (p *prober) probeICMP(addr netip.Addr) *Result {
+	var pg *pinger
+	if addr.Is4() {
+		p.once4.Do(func() { p.pinger4 = openPinger(false) })
+		pg = p.pinger4
+	} else {
+		p.once6.Do(func() { p.pinger6 = openPinger(true) })
+		pg = p.pinger6
+	}
+
+	var rtt time.Duration
+	var ok bool
+	if pg != nil {
+		rtt, ok = pg.Ping(addr, p.timeout)
+	} else {
+		rtt, ok = pingCommand(addr, p.timeout)
+	}
+	if !ok {
+		return nil
+	}
+	return &Result{IP: addr.String(), addr: addr, RTT: milliseconds(rtt)}
+}
+
+func openPinger(ipv6 bool) *pinger {
+	pg, err := newPinger(ipv6)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: can't open ICMP socket (%v), using ping command\n", err)
+		return nil
+	}
+	return pg
+}
+
+// pingCommand runs ping, RTT includes start of the process
+func pingCommand(addr netip.Addr, timeout time.Duration) (time.Duration, bool) {
+	seconds := strconv.Itoa(int(math.Ceil(max(timeout, time.Second).Seconds())))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+2*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := exec.CommandContext(ctx, "ping", "-c", "1", "-W", seconds, addr.String()).Run()
+	return time.Since(start), err == nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/100) / 10
+}
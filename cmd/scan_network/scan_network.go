@@ -1,90 +1,305 @@
 package scan_network
 
 import (
-	"encoding/binary"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
-	"os/exec"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/sikalabs/slr/cmd/root"
 	"github.com/spf13/cobra"
 )
 
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// maxIPv6Sweep is the largest IPv6 network (/112) which can be swept,
+// larger networks have to be given as explicit targets
+const maxIPv6Sweep = 112
+
+var FlagConcurrency int
+var FlagTimeout time.Duration
+var FlagPorts []int
+var FlagNoDNS bool
+var FlagFile string
+var FlagOutput string
+
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().IntVarP(&FlagConcurrency, "concurrency", "c", 256, "Maximum number of hosts probed at once")
+	Cmd.Flags().DurationVarP(&FlagTimeout, "timeout", "t", time.Second, "Timeout of ICMP echo or TCP connect")
+	Cmd.Flags().IntSliceVarP(&FlagPorts, "ports", "p", nil, "TCP connect to ports instead of ICMP ping, e.g. 22,80,443")
+	Cmd.Flags().BoolVar(&FlagNoDNS, "no-dns", false, "Don't resolve reverse DNS names")
+	Cmd.Flags().StringVarP(&FlagFile, "file", "f", "", "File with targets, one IP or CIDR per line (- for stdin)")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", OutputTable, "Output format (table, json)")
 }
 
 var Cmd = &cobra.Command{
-	Use:   "scan-network <cidr>",
+	Use:   "scan-network <cidr|ip>...",
 	Short: "Scan all IPs in a network range (e.g. 192.168.1.0/24)",
-	Args:  cobra.ExactArgs(1),
+	Long: `Scan all IPs in network ranges or explicit targets.
+
+Hosts are probed with ICMP echo using unprivileged ping sockets (udp4,
+udp6) if the kernel allows them (sysctl net.ipv4.ping_group_range), raw
+sockets if running as root, or the ping command as fallback. With --ports
+hosts are probed with TCP connect, a refused connection counts as up.
+
+IPv6 networks up to /112 can be swept, otherwise give explicit IPv6
+targets as arguments or in --file.`,
+	Example: `  slr scan-network 192.168.1.0/24
+  slr scan-network 10.0.0.0/16 -c 1024 -t 500ms -o json
+  slr scan-network 192.168.1.0/24 --ports 22,80,443
+  slr scan-network 2001:db8::1 2001:db8::10 fe80::1%eth0
+  slr scan-network -f hosts.txt`,
+	Args: func(c *cobra.Command, args []string) error {
+		if FlagFile == "" && len(args) == 0 {
+			return fmt.Errorf("requires at least 1 target or --file")
+		}
+		return nil
+	},
 	Run: func(c *cobra.Command, args []string) {
-		scanNetwork(args[0])
+		if err := scanNetwork(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	},
 }
 
-func scanNetwork(cidr string) {
-	ip, ipNet, err := net.ParseCIDR(cidr)
+// Result is one host which is up
+type Result struct {
+	IP        string  `json:"ip"`
+	RTT       float64 `json:"rttMs"`
+	Hostname  string  `json:"hostname,omitempty"`
+	MAC       string  `json:"mac,omitempty"`
+	OpenPorts []int   `json:"openPorts,omitempty"`
+	addr      netip.Addr
+}
+
+func scanNetwork(args []string) error {
+	if FlagOutput != OutputTable && FlagOutput != OutputJSON {
+		return fmt.Errorf("unknown output %q (table, json)", FlagOutput)
+	}
+	if FlagConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	for _, port := range FlagPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if FlagFile != "" {
+		lines, err := readTargetsFile(FlagFile)
+		if err != nil {
+			return err
+		}
+		args = append(args, lines...)
+	}
+	targets, err := parseTargets(args)
 	if err != nil {
-		fmt.Printf("Error: invalid CIDR %q: %v\n", cidr, err)
-		return
+		return err
+	}
+
+	total := 0
+	for _, t := range targets {
+		total += hostCount(t)
+	}
+	mode := "ICMP"
+	if len(FlagPorts) > 0 {
+		mode = "TCP " + joinInts(FlagPorts)
 	}
+	fmt.Fprintf(os.Stderr, "Scanning %d hosts (%s)...\n", total, mode)
 
-	ips := allIPs(ip, ipNet)
-	fmt.Printf("Scanning %d hosts in %s...\n", len(ips), cidr)
+	probe := newProber(FlagPorts, FlagTimeout)
+	defer probe.Close()
 
+	jobs := make(chan netip.Addr)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
-	var up []string
-
-	for _, host := range ips {
+	results := []*Result{}
+	for range min(FlagConcurrency, total) {
 		wg.Add(1)
-		go func(host string) {
+		go func() {
 			defer wg.Done()
-			if ping(host) {
+			for addr := range jobs {
+				result := probe.Probe(addr)
+				if result == nil {
+					continue
+				}
+				if !FlagNoDNS {
+					result.Hostname = reverseDNS(addr, FlagTimeout)
+				}
 				mu.Lock()
-				up = append(up, host)
+				results = append(results, result)
 				mu.Unlock()
 			}
-		}(host)
+		}()
 	}
-
+	for _, t := range targets {
+		for addr := range hosts(t) {
+			jobs <- addr
+		}
+	}
+	close(jobs)
 	wg.Wait()
 
-	if len(up) == 0 {
+	slices.SortFunc(results, func(a, b *Result) int {
+		return a.addr.Compare(b.addr)
+	})
+	macs := neighbors()
+	for _, r := range results {
+		r.MAC = macs[r.addr.WithZone("")]
+	}
+
+	if FlagOutput == OutputJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	printTable(results)
+	return nil
+}
+
+func printTable(results []*Result) {
+	if len(results) == 0 {
 		fmt.Println("No hosts responded.")
 		return
 	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "IP\tRTT\tHOSTNAME\tMAC"
+	if len(FlagPorts) > 0 {
+		header += "\tOPEN PORTS"
+	}
+	fmt.Fprintln(w, header)
+	for _, r := range results {
+		line := fmt.Sprintf("%s\t%.1fms\t%s\t%s", r.IP, r.RTT, r.Hostname, r.MAC)
+		if len(FlagPorts) > 0 {
+			line += "\t" + joinInts(r.OpenPorts)
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	fmt.Printf("\nUp: %d\n", len(results))
+}
 
-	fmt.Printf("\nUp (%d):\n", len(up))
-	for _, h := range up {
-		fmt.Println(" ", h)
+func readTargetsFile(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
 	}
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	return targets, nil
 }
 
-func allIPs(base net.IP, ipNet *net.IPNet) []string {
-	// Work with 4-byte representation
-	ip4 := base.To4()
-	if ip4 == nil {
-		fmt.Println("Error: only IPv4 networks are supported")
-		return nil
+// target is single address (addr, keeps zone of link-local IPv6) or
+// network (prefix)
+type target struct {
+	addr   netip.Addr
+	prefix netip.Prefix
+}
+
+// parseTargets parses IPs and CIDRs
+func parseTargets(args []string) ([]target, error) {
+	targets := []target{}
+	for _, arg := range args {
+		if !strings.Contains(arg, "/") {
+			addr, err := netip.ParseAddr(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid target %q: %v", arg, err)
+			}
+			targets = append(targets, target{addr: addr.Unmap()})
+			continue
+		}
+		prefix, err := netip.ParsePrefix(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %v", arg, err)
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().Is6() && prefix.Bits() < maxIPv6Sweep {
+			return nil, fmt.Errorf("IPv6 network %s is too large to scan (max /%d), use explicit targets", arg, maxIPv6Sweep)
+		}
+		targets = append(targets, target{prefix: prefix})
+	}
+	return targets, nil
+}
+
+// hostCount is number of hosts yielded by hosts
+func hostCount(t target) int {
+	if !t.prefix.IsValid() {
+		return 1
+	}
+	p := t.prefix
+	n := 1 << (p.Addr().BitLen() - p.Bits())
+	if p.Addr().Is4() && p.Bits() < 31 {
+		n -= 2
+	}
+	return n
+}
+
+// hosts yields the single address, or addresses of the prefix, IPv4
+// networks without network and broadcast address (except /31 and /32)
+func hosts(t target) func(yield func(netip.Addr) bool) {
+	return func(yield func(netip.Addr) bool) {
+		if !t.prefix.IsValid() {
+			yield(t.addr)
+			return
+		}
+		p := t.prefix
+		addr := p.Addr()
+		skipEdges := addr.Is4() && p.Bits() < 31
+		if skipEdges {
+			addr = addr.Next()
+		}
+		for ; addr.IsValid() && p.Contains(addr); addr = addr.Next() {
+			if skipEdges && !p.Contains(addr.Next()) {
+				return
+			}
+			if !yield(addr) {
+				return
+			}
+		}
 	}
-	mask := ipNet.Mask
-	network := binary.BigEndian.Uint32([]byte(ipNet.IP.To4()))
-	broadcast := network | ^binary.BigEndian.Uint32([]byte(mask))
+}
 
-	var ips []string
-	// Skip network address (network) and broadcast address (broadcast)
-	for n := network + 1; n < broadcast; n++ {
-		b := make([]byte, 4)
-		binary.BigEndian.PutUint32(b, n)
-		ips = append(ips, net.IP(b).String())
+func reverseDNS(addr netip.Addr, timeout time.Duration) string {
+	ctx, cancel := context.WithTimeout(context.Background(), max(timeout, time.Second))
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr.WithZone("").String())
+	if err != nil || len(names) == 0 {
+		return ""
 	}
-	return ips
+	return strings.TrimSuffix(names[0], ".")
 }
 
-func ping(host string) bool {
-	err := exec.Command("ping", "-c", "1", "-W", "1", host).Run()
-	return err == nil
+func joinInts(ints []int) string {
+	s := make([]string, 0, len(ints))
+	for _, i := range ints {
+		s = append(s, strconv.Itoa(i))
+	}
+	return strings.Join(s, ",")
 }
@@ -0,0 +1,74 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// eventDocument converts event to document written by all sinks. The
+// original flat fields (id, timestamp, namespace, kind, name, reason, type,
+// message) are kept so existing queries keep working, the rest is the full
+// event.
+func eventDocument(e *corev1.Event) map[string]any {
+	return map[string]any{
+		"id":        string(e.UID),
+		"timestamp": e.CreationTimestamp.Unix(),
+		"namespace": e.Namespace,
+		"kind":      e.InvolvedObject.Kind,
+		"name":      e.InvolvedObject.Name,
+		"reason":    e.Reason,
+		"type":      e.Type,
+		"message":   e.Message,
+
+		"eventName":       e.Name,
+		"resourceVersion": e.ResourceVersion,
+		"involvedObject": map[string]any{
+			"kind":            e.InvolvedObject.Kind,
+			"namespace":       e.InvolvedObject.Namespace,
+			"name":            e.InvolvedObject.Name,
+			"uid":             string(e.InvolvedObject.UID),
+			"apiVersion":      e.InvolvedObject.APIVersion,
+			"resourceVersion": e.InvolvedObject.ResourceVersion,
+			"fieldPath":       e.InvolvedObject.FieldPath,
+		},
+		"count":          e.Count,
+		"firstTimestamp": timeOrNil(e.FirstTimestamp),
+		"lastTimestamp":  timeOrNil(e.LastTimestamp),
+		"eventTime":      microTimeOrNil(e.EventTime),
+		"source": map[string]any{
+			"component": e.Source.Component,
+			"host":      e.Source.Host,
+		},
+		"reportingController": e.ReportingController,
+		"reportingInstance":   e.ReportingInstance,
+		"action":              e.Action,
+	}
+}
+
+func timeOrNil(t metav1.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
+
+func microTimeOrNil(t metav1.MicroTime) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
+
+// eventTime returns the best known time of the event occurrence
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,58 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// eventFilter selects events which are sent to sinks, empty filter
+// matches all events
+type eventFilter struct {
+	namespaces   map[string]bool
+	kinds        map[string]bool
+	warningsOnly bool
+	reason       *regexp.Regexp
+}
+
+func newEventFilter(namespaces, kinds []string, warningsOnly bool, reason string) (*eventFilter, error) {
+	f := &eventFilter{
+		namespaces:   toSet(namespaces),
+		kinds:        toSet(kinds),
+		warningsOnly: warningsOnly,
+	}
+	if reason != "" {
+		re, err := regexp.Compile(reason)
+		if err != nil {
+			return nil, err
+		}
+		f.reason = re
+	}
+	return f, nil
+}
+
+func (f *eventFilter) Match(e *corev1.Event) bool {
+	if len(f.namespaces) > 0 && !f.namespaces[e.Namespace] {
+		return false
+	}
+	if len(f.kinds) > 0 && !f.kinds[e.InvolvedObject.Kind] {
+		return false
+	}
+	if f.warningsOnly && e.Type != corev1.EventTypeWarning {
+		return false
+	}
+	if f.reason != nil && !f.reason.MatchString(e.Reason) {
+		return false
+	}
+	return true
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,97 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// runWithLeaderElection runs run only while this replica holds the Lease,
+// so more replicas never write the same events twice. When the Lease is
+// lost, run's context is cancelled and error is returned (the pod restarts
+// and becomes standby). On shutdown the Lease is released only after run
+// returns (final flush), so the next leader doesn't write the same events.
+func runWithLeaderElection(ctx context.Context, clientset kubernetes.Interface, namespace, name string, run func(ctx context.Context) error) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	// OnStartedLeading runs in its own goroutine, RunOrDie doesn't wait
+	// for it, wait for the final flush here
+	var runErr error
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	// Leader election has its own context, cancelling it releases the
+	// Lease. On shutdown only run is cancelled and the Lease is released
+	// once it returns, or right away if we are not leading.
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	defer cancelLeader()
+	stop := context.AfterFunc(ctx, func() {
+		select {
+		case <-started:
+		default:
+			cancelLeader()
+		}
+	})
+	defer stop()
+
+	fmt.Printf("Waiting for leader Lease %s/%s as %s\n", namespace, name, identity)
+	leaderelection.RunOrDie(leaderCtx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leadingCtx context.Context) {
+				close(started)
+				state.setLeading(true)
+				fmt.Printf("Acquired leader Lease %s/%s\n", namespace, name)
+				runCtx, cancelRun := context.WithCancel(leadingCtx)
+				stopRun := context.AfterFunc(ctx, cancelRun)
+				runErr = run(runCtx)
+				stopRun()
+				cancelRun()
+				close(done)
+				cancelLeader()
+			},
+			OnStoppedLeading: func() {
+				state.setLeading(false)
+			},
+			OnNewLeader: func(current string) {
+				if current != identity {
+					fmt.Printf("Current leader is %s\n", current)
+				}
+			},
+		},
+	})
+
+	select {
+	case <-started:
+	default:
+		return nil
+	}
+	<-done
+	if runErr != nil {
+		return runErr
+	}
+	if ctx.Err() == nil {
+		return fmt.Errorf("lost leader Lease %s/%s", namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slr_kubernetes_events_received_total",
+		Help: "Kubernetes events received from API server (before filters)",
+	}, []string{"type", "namespace", "reason"})
+	eventsWritten = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slr_kubernetes_events_written_total",
+		Help: "Kubernetes events written to all sinks",
+	}, []string{"type", "namespace", "reason"})
+	sinkWriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slr_kubernetes_events_sink_write_errors_total",
+		Help: "Failed batch writes per sink",
+	}, []string{"sink"})
+	watchRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slr_kubernetes_events_watch_restarts_total",
+		Help: "Watch reconnects (reconnect) and relists after 410 Gone (expired)",
+	}, []string{"reason"})
+	pendingEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "slr_kubernetes_events_pending",
+		Help: "Events waiting for the next bulk write",
+	})
+	lastWrite = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "slr_kubernetes_events_last_write_timestamp_seconds",
+		Help: "Unix timestamp of the last successful write to sinks",
+	})
+	isLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "slr_kubernetes_events_leader",
+		Help: "1 if this replica holds the leader Lease (always 1 without --leader-elect)",
+	})
+)
+
+func registerMetrics() {
+	prometheus.MustRegister(eventsReceived)
+	prometheus.MustRegister(eventsWritten)
+	prometheus.MustRegister(sinkWriteErrors)
+	prometheus.MustRegister(watchRestarts)
+	prometheus.MustRegister(pendingEvents)
+	prometheus.MustRegister(lastWrite)
+	prometheus.MustRegister(isLeader)
+}
@@ -0,0 +1,101 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// health is shared state of the streamer for /readyz
+type health struct {
+	mu            sync.Mutex
+	leading       bool
+	watchOK       bool
+	sinkOK        bool
+	lastError     string
+	leaderElected bool
+}
+
+var state = &health{sinkOK: true}
+
+func (h *health) setWatch(ok bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watchOK = ok
+	if err != nil {
+		h.lastError = "watch: " + err.Error()
+	}
+}
+
+func (h *health) setSink(ok bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sinkOK = ok
+	if err != nil {
+		h.lastError = "sink: " + err.Error()
+	}
+}
+
+func (h *health) setLeading(leading bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leading = leading
+	if leading {
+		isLeader.Set(1)
+	} else {
+		isLeader.Set(0)
+	}
+}
+
+// ready returns nil when watch is connected and the last write to sinks
+// succeeded. Standby replica (waiting for the Lease) is ready too,
+// otherwise Deployment with more replicas would never become available.
+func (h *health) ready() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.leaderElected && !h.leading {
+		return "standby", nil
+	}
+	if !h.watchOK {
+		return "", fmt.Errorf("watch not connected: %s", h.lastError)
+	}
+	if !h.sinkOK {
+		return "", fmt.Errorf("sink not reachable: %s", h.lastError)
+	}
+	return "ok", nil
+}
+
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		msg, err := state.ready()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, msg)
+	})
+	return mux
+}
+
+// startServer serves /healthz, /readyz and /metrics until ctx is done,
+// server error (e.g. address in use) cancels ctx with the error as cause.
+// The returned function waits for graceful shutdown.
+func startServer(ctx context.Context, cancel context.CancelCauseFunc) (wait func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fmt.Printf("Serving /healthz, /readyz and /metrics\n")
+		if err := FlagServer.Serve(ctx, newHandler()); err != nil {
+			cancel(fmt.Errorf("http server: %w", err))
+		}
+	}()
+	return func() { <-done }
+}
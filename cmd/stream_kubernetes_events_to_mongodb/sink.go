@@ -0,0 +1,147 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	SinkMongoDB  = "mongodb"
+	SinkPostgres = "postgres"
+	SinkLoki     = "loki"
+	SinkWebhook  = "webhook"
+	SinkFile     = "file"
+)
+
+var Sinks = []string{SinkMongoDB, SinkPostgres, SinkLoki, SinkWebhook, SinkFile}
+
+// sink receives batches of (already filtered) events. Write must be safe
+// to call again with the same events, a failed batch is retried.
+type sink interface {
+	Name() string
+	Write(ctx context.Context, events []*corev1.Event) error
+	Close(ctx context.Context) error
+}
+
+// checkpointer stores last processed resourceVersion, so the stream can be
+// resumed after restart. MongoDB and Postgres sinks store it next to the
+// events, --checkpoint-file can be used with any sink.
+type checkpointer interface {
+	LoadResourceVersion(ctx context.Context) (string, error)
+	SaveResourceVersion(ctx context.Context, resourceVersion string) error
+}
+
+func newSink(ctx context.Context, name string) (sink, error) {
+	switch name {
+	case SinkMongoDB:
+		return newMongoSink(ctx, FlagMongoURI, FlagMongoDatabase, FlagCollection, FlagCheckpointCollection, FlagNamespace)
+	case SinkPostgres:
+		return newPostgresSink(ctx, FlagPostgresDSN, FlagPostgresTable, FlagNamespace)
+	case SinkLoki:
+		return newLokiSink(FlagLokiURL, FlagLokiTenant, FlagLokiLabels)
+	case SinkWebhook:
+		return newWebhookSink(FlagWebhookURL, FlagWebhookHeaders)
+	case SinkFile:
+		return newFileSink(FlagFilePath, FlagFileMaxSizeMB*1024*1024, FlagFileMaxBackups)
+	default:
+		return nil, fmt.Errorf("unknown sink %q, supported sinks: %s", name, strings.Join(Sinks, ", "))
+	}
+}
+
+// multiSink writes every batch to all sinks. The batch is retried until
+// all sinks accept it (new events may be appended meanwhile), written
+// keeps how many events of the batch each sink already has, so a failed
+// sink doesn't cause duplicates in the others.
+type multiSink struct {
+	sinks   []sink
+	written []int
+}
+
+func (m *multiSink) add(s sink) {
+	m.sinks = append(m.sinks, s)
+	m.written = append(m.written, 0)
+}
+
+func (m *multiSink) Name() string {
+	names := make([]string, 0, len(m.sinks))
+	for _, s := range m.sinks {
+		names = append(names, s.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (m *multiSink) Write(ctx context.Context, events []*corev1.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var errs []error
+	for i, s := range m.sinks {
+		if m.written[i] >= len(events) {
+			continue
+		}
+		if err := s.Write(ctx, events[m.written[i]:]); err != nil {
+			sinkWriteErrors.WithLabelValues(s.Name()).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		m.written[i] = len(events)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	// The whole batch is written, the next one starts from scratch
+	clear(m.written)
+	return nil
+}
+
+func (m *multiSink) Close(ctx context.Context) error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkpointerFor returns --checkpoint-file if set, otherwise the first
+// sink which can store checkpoints, nil if there is none
+func checkpointerFor(sinks *multiSink, checkpointFile string) checkpointer {
+	if checkpointFile != "" {
+		return fileCheckpointer{path: checkpointFile}
+	}
+	for _, s := range sinks.sinks {
+		if c, ok := s.(checkpointer); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+type fileCheckpointer struct {
+	path string
+}
+
+func (f fileCheckpointer) LoadResourceVersion(_ context.Context) (string, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (f fileCheckpointer) SaveResourceVersion(_ context.Context, resourceVersion string) error {
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(resourceVersion+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
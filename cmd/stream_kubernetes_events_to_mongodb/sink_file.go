@@ -0,0 +1,103 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fileSink appends events as JSON lines. When the file would grow over
+// maxSize it's rotated to path.1 (path.1 to path.2, ...), at most
+// maxBackups old files are kept.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func newFileSink(path string, maxSize int64, maxBackups int) (*fileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("--file-path is required for file sink")
+	}
+
+	f := &fileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *fileSink) Name() string { return SinkFile }
+
+func (f *fileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *fileSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if f.maxBackups < 1 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *fileSink) Write(_ context.Context, events []*corev1.Event) error {
+	for _, e := range events {
+		line, err := json.Marshal(eventDocument(e))
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+			if err := f.rotate(); err != nil {
+				return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+			}
+		}
+
+		n, err := f.file.Write(line)
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fileSink) Close(_ context.Context) error {
+	return f.file.Close()
+}
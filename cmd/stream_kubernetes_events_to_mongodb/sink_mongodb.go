@@ -0,0 +1,82 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type mongoSink struct {
+	client      *mongo.Client
+	events      *mongo.Collection
+	checkpoints *mongo.Collection
+	// checkpoint document id, one per watched namespace
+	checkpointID string
+}
+
+func newMongoSink(ctx context.Context, uri, database, collection, checkpointCollection, namespace string) (*mongoSink, error) {
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mongodb: %w", err)
+	}
+
+	db := client.Database(database)
+	return &mongoSink{
+		client:       client,
+		events:       db.Collection(collection),
+		checkpoints:  db.Collection(checkpointCollection),
+		checkpointID: collection + "/" + namespace,
+	}, nil
+}
+
+func (m *mongoSink) Name() string { return SinkMongoDB }
+
+// Write upserts events (by event UID) in one unordered bulk write
+func (m *mongoSink) Write(ctx context.Context, events []*corev1.Event) error {
+	models := make([]mongo.WriteModel, 0, len(events))
+	for _, e := range events {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"id": string(e.UID)}).
+			SetUpdate(bson.M{"$set": eventDocument(e)}).
+			SetUpsert(true))
+	}
+
+	_, err := m.events.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *mongoSink) Close(ctx context.Context) error {
+	return m.client.Disconnect(ctx)
+}
+
+// LoadResourceVersion returns last stored resourceVersion, empty string if
+// there is none
+func (m *mongoSink) LoadResourceVersion(ctx context.Context) (string, error) {
+	var checkpoint struct {
+		ResourceVersion string `bson:"resourceVersion"`
+	}
+	err := m.checkpoints.FindOne(ctx, bson.M{"_id": m.checkpointID}).Decode(&checkpoint)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return checkpoint.ResourceVersion, nil
+}
+
+func (m *mongoSink) SaveResourceVersion(ctx context.Context, resourceVersion string) error {
+	_, err := m.checkpoints.UpdateOne(
+		ctx,
+		bson.M{"_id": m.checkpointID},
+		bson.M{"$set": bson.M{"resourceVersion": resourceVersion}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,164 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// postgresSink upserts events into table with the full event in JSONB
+// column, both tables are created on start if they don't exist
+type postgresSink struct {
+	db               *sql.DB
+	table            string
+	checkpointsTable string
+	checkpointID     string
+}
+
+func newPostgresSink(ctx context.Context, dsn, table, namespace string) (*postgresSink, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("--postgres-dsn is required for postgres sink")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &postgresSink{
+		db:               db,
+		table:            pq.QuoteIdentifier(table),
+		checkpointsTable: pq.QuoteIdentifier(table + "_checkpoints"),
+		checkpointID:     table + "/" + namespace,
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+p.table+` (
+	uid TEXT PRIMARY KEY,
+	namespace TEXT NOT NULL,
+	type TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	name TEXT NOT NULL,
+	event_time TIMESTAMPTZ NOT NULL,
+	data JSONB NOT NULL
+)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create table %s: %w", p.table, err)
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+p.checkpointsTable+` (
+	id TEXT PRIMARY KEY,
+	resource_version TEXT NOT NULL
+)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create table %s: %w", p.checkpointsTable, err)
+	}
+
+	return p, nil
+}
+
+func (p *postgresSink) Name() string { return SinkPostgres }
+
+// postgresInsertChunk is number of rows in one INSERT, each row has 8
+// parameters and Postgres allows at most 65535 parameters per statement
+const postgresInsertChunk = 1000
+
+// Write upserts events in multi-row INSERT ... ON CONFLICT statements of
+// postgresInsertChunk rows, all in one transaction
+func (p *postgresSink) Write(ctx context.Context, events []*corev1.Event) error {
+	// the same event can be in one batch more times (count update),
+	// ON CONFLICT can't update the same row twice in one statement
+	latest := make(map[string]*corev1.Event, len(events))
+	order := make([]string, 0, len(events))
+	for _, e := range events {
+		uid := string(e.UID)
+		if _, ok := latest[uid]; !ok {
+			order = append(order, uid)
+		}
+		latest[uid] = e
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(order); start += postgresInsertChunk {
+		end := min(start+postgresInsertChunk, len(order))
+		err = p.insert(ctx, tx, latest, order[start:end])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *postgresSink) insert(ctx context.Context, tx *sql.Tx, latest map[string]*corev1.Event, uids []string) error {
+	placeholders := make([]string, 0, len(uids))
+	args := make([]any, 0, len(uids)*8)
+	for i, uid := range uids {
+		e := latest[uid]
+		data, err := json.Marshal(eventDocument(e))
+		if err != nil {
+			return err
+		}
+		n := i * 8
+		placeholders = append(placeholders, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8,
+		))
+		args = append(args, uid, e.Namespace, e.Type, e.Reason,
+			e.InvolvedObject.Kind, e.InvolvedObject.Name, eventTime(e), string(data))
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO `+p.table+`
+	(uid, namespace, type, reason, kind, name, event_time, data)
+VALUES `+strings.Join(placeholders, ", ")+`
+ON CONFLICT (uid) DO UPDATE SET
+	namespace = EXCLUDED.namespace,
+	type = EXCLUDED.type,
+	reason = EXCLUDED.reason,
+	kind = EXCLUDED.kind,
+	name = EXCLUDED.name,
+	event_time = EXCLUDED.event_time,
+	data = EXCLUDED.data`, args...)
+	return err
+}
+
+func (p *postgresSink) Close(_ context.Context) error {
+	return p.db.Close()
+}
+
+func (p *postgresSink) LoadResourceVersion(ctx context.Context) (string, error) {
+	var resourceVersion string
+	err := p.db.QueryRowContext(ctx,
+		`SELECT resource_version FROM `+p.checkpointsTable+` WHERE id = $1`,
+		p.checkpointID,
+	).Scan(&resourceVersion)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return resourceVersion, err
+}
+
+func (p *postgresSink) SaveResourceVersion(ctx context.Context, resourceVersion string) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO `+p.checkpointsTable+` (id, resource_version)
+VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET resource_version = EXCLUDED.resource_version`,
+		p.checkpointID, resourceVersion,
+	)
+	return err
+}
@@ -0,0 +1,283 @@
+package stream_kubernetes_events_to_mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// eventsWatcher implements cache.WatcherWithContext for RetryWatcher,
+// every call after the first one is a reconnect
+type eventsWatcher struct {
+	clientset kubernetes.Interface
+	namespace string
+	calls     int
+}
+
+func (w *eventsWatcher) WatchWithContext(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	if w.calls > 0 {
+		watchRestarts.WithLabelValues("reconnect").Inc()
+	}
+	w.calls++
+
+	options.AllowWatchBookmarks = true
+	watcher, err := w.clientset.CoreV1().Events(w.namespace).Watch(ctx, options)
+	state.setWatch(err == nil, err)
+	return watcher, err
+}
+
+type streamer struct {
+	clientset     kubernetes.Interface
+	namespace     string
+	sink          sink
+	checkpoint    checkpointer
+	filter        *eventFilter
+	batchSize     int
+	maxPending    int
+	flushInterval time.Duration
+
+	batch                []*corev1.Event
+	retry                *time.Timer
+	retryDelay           time.Duration
+	resourceVersion      string
+	savedResourceVersion string
+}
+
+// Run streams events until ctx is done, pending events are flushed before
+// it returns. It resumes from resourceVersion stored by checkpointer, if
+// there is none (first run) or it is too old (410 Gone), all events are
+// listed and written first.
+func (s *streamer) Run(ctx context.Context) error {
+	var resourceVersion string
+	if s.checkpoint != nil {
+		var err error
+		resourceVersion, err = s.checkpoint.LoadResourceVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load resourceVersion checkpoint: %w", err)
+		}
+		if resourceVersion != "" {
+			fmt.Printf("Resuming from resourceVersion %s\n", resourceVersion)
+		}
+	}
+
+	for {
+		if resourceVersion == "" {
+			var err error
+			resourceVersion, err = s.relist(ctx)
+			if ctx.Err() != nil {
+				return s.finalFlush()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list events: %w", err)
+			}
+		}
+
+		expired, err := s.watch(ctx, resourceVersion)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			return nil
+		}
+
+		watchRestarts.WithLabelValues("expired").Inc()
+		fmt.Printf("resourceVersion %s is too old, relisting\n", s.resourceVersion)
+		resourceVersion = ""
+	}
+}
+
+// relist upserts all current events and returns resourceVersion of the list
+func (s *streamer) relist(ctx context.Context) (string, error) {
+	options := metav1.ListOptions{Limit: 500}
+	count := 0
+	for {
+		list, err := s.clientset.CoreV1().Events(s.namespace).List(ctx, options)
+		if err != nil {
+			return "", err
+		}
+		for i := range list.Items {
+			e := &list.Items[i]
+			eventsReceived.WithLabelValues(e.Type, e.Namespace, e.Reason).Inc()
+			if !s.filter.Match(e) {
+				continue
+			}
+			s.batch = append(s.batch, e)
+			pendingEvents.Set(float64(len(s.batch)))
+			if len(s.batch) >= s.batchSize {
+				if err := s.flush(ctx); err != nil {
+					return "", err
+				}
+			}
+		}
+		count += len(list.Items)
+
+		if list.Continue == "" {
+			s.resourceVersion = list.ResourceVersion
+			if err := s.flush(ctx); err != nil {
+				return "", err
+			}
+			fmt.Printf("Listed %d events at resourceVersion %s\n", count, list.ResourceVersion)
+			return list.ResourceVersion, nil
+		}
+		options.Continue = list.Continue
+	}
+}
+
+// watch consumes RetryWatcher, which reconnects on its own whenever API
+// server closes the connection. It returns expired=true on 410 Gone.
+func (s *streamer) watch(ctx context.Context, resourceVersion string) (expired bool, err error) {
+	watcher, err := watchtools.NewRetryWatcherWithContext(ctx, resourceVersion, &eventsWatcher{
+		clientset: s.clientset,
+		namespace: s.namespace,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to watch events: %w", err)
+	}
+	defer watcher.Stop()
+	defer state.setWatch(false, nil)
+	defer s.stopRetry()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	paused := false
+	for {
+		// stop reading the watch while sinks are failing and too many
+		// events are pending, RetryWatcher resumes from the last one read
+		results := watcher.ResultChan()
+		if len(s.batch) >= s.maxPending {
+			if !paused {
+				fmt.Printf("Error: %d events pending, pausing watch until sinks recover\n", len(s.batch))
+			}
+			paused = true
+			results = nil
+		} else if paused {
+			fmt.Printf("Resuming watch\n")
+			paused = false
+		}
+
+		var retry <-chan time.Time
+		if s.retry != nil {
+			retry = s.retry.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, s.finalFlush()
+		case <-retry:
+			s.retry = nil
+			s.tryFlush(ctx)
+		case <-ticker.C:
+			s.tryFlush(ctx)
+		case event, ok := <-results:
+			if !ok {
+				return false, s.flush(ctx)
+			}
+
+			switch event.Type {
+			case watch.Error:
+				statusErr := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(statusErr) || apierrors.IsGone(statusErr) {
+					return true, s.flush(ctx)
+				}
+				fmt.Printf("Error: watch: %v\n", statusErr)
+			case watch.Bookmark:
+				if e, ok := event.Object.(*corev1.Event); ok {
+					s.resourceVersion = e.ResourceVersion
+				}
+			case watch.Added, watch.Modified:
+				e, ok := event.Object.(*corev1.Event)
+				if !ok {
+					continue
+				}
+				s.resourceVersion = e.ResourceVersion
+				eventsReceived.WithLabelValues(e.Type, e.Namespace, e.Reason).Inc()
+				if !s.filter.Match(e) {
+					continue
+				}
+				s.batch = append(s.batch, e)
+				pendingEvents.Set(float64(len(s.batch)))
+				fmt.Printf(
+					"id=%s namespace=%s kind=%s name=%s reason=%s message=%s\n",
+					e.UID, e.Namespace, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Reason, e.Message,
+				)
+				if len(s.batch) >= s.batchSize {
+					s.tryFlush(ctx)
+				}
+			case watch.Deleted:
+				// Events expire (1h by default), keep them in sinks
+				if e, ok := event.Object.(*corev1.Event); ok {
+					s.resourceVersion = e.ResourceVersion
+				}
+			}
+		}
+	}
+}
+
+// flush writes pending events and then stores resourceVersion checkpoint,
+// the checkpoint never gets ahead of written events
+func (s *streamer) flush(ctx context.Context) error {
+	if len(s.batch) > 0 {
+		if err := s.sink.Write(ctx, s.batch); err != nil {
+			state.setSink(false, err)
+			return fmt.Errorf("failed to write %d events to %s: %w", len(s.batch), s.sink.Name(), err)
+		}
+		state.setSink(true, nil)
+		lastWrite.SetToCurrentTime()
+		for _, e := range s.batch {
+			eventsWritten.WithLabelValues(e.Type, e.Namespace, e.Reason).Inc()
+		}
+		s.batch = s.batch[:0]
+		pendingEvents.Set(0)
+	}
+
+	if s.checkpoint == nil || s.resourceVersion == "" || s.resourceVersion == s.savedResourceVersion {
+		return nil
+	}
+	if err := s.checkpoint.SaveResourceVersion(ctx, s.resourceVersion); err != nil {
+		return fmt.Errorf("failed to save resourceVersion checkpoint: %w", err)
+	}
+	s.savedResourceVersion = s.resourceVersion
+	return nil
+}
+
+// tryFlush keeps pending events on error and retries with exponential
+// backoff (1s up to 1m), no flush is attempted until the retry timer fires
+func (s *streamer) tryFlush(ctx context.Context) {
+	if s.retry != nil {
+		return
+	}
+	if err := s.flush(ctx); err != nil {
+		s.retryDelay = min(max(2*s.retryDelay, time.Second), time.Minute)
+		fmt.Printf("Error: %v, retrying in %s\n", err, s.retryDelay)
+		s.retry = time.NewTimer(s.retryDelay)
+		return
+	}
+	s.retryDelay = 0
+}
+
+func (s *streamer) stopRetry() {
+	if s.retry != nil {
+		s.retry.Stop()
+		s.retry = nil
+	}
+}
+
+// finalFlush is used on shutdown (SIGTERM, lost Lease), the main context
+// is already cancelled at that point
+func (s *streamer) finalFlush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("Flushed pending events, stopping\n")
+	return nil
+}
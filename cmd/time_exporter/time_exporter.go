@@ -10,17 +10,30 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/httpserver"
 	"github.com/spf13/cobra"
 )
 
+var FlagServer *httpserver.Options
+
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	listen := ":8000"
+	if os.Getenv("PORT") != "" {
+		listen = ":" + os.Getenv("PORT")
+	}
+	FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{
+		Listen:       listen,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	})
 }
 
 var Cmd = &cobra.Command{
-	Use:   "time-exporter",
-	Short: "Example Prometheus exporter with time metrics",
-	Args:  cobra.NoArgs,
+	Use:        "time-exporter",
+	Short:      "Example Prometheus exporter with time metrics",
+	Deprecated: "use slr exporter with collectors defined in YAML",
+	Args:       cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
 		timeExporter()
 	},
@@ -60,15 +73,11 @@ func timeExporter() {
 		}
 	}()
 
-	http.Handle("/metrics", promhttp.Handler())
-
-	port := "8000"
-	if os.Getenv("PORT") != "" {
-		port = os.Getenv("PORT")
-	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
 
-	fmt.Println("Starting server on 0.0.0.0:" + port + ", see http://localhost:" + port + "/metrics")
-	err := http.ListenAndServe(":"+port, nil)
+	fmt.Println("Metrics on " + FlagServer.URL() + "/metrics")
+	err := FlagServer.ListenAndServe(mux)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
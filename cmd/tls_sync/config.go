@@ -0,0 +1,115 @@
+package tls_sync
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Example config:
+//
+//	Entries:
+//	  - Name: gitlab
+//	    Source:
+//	      Kubernetes:
+//	        Kubeconfig: /root/.kube/config
+//	        Namespace: gitlab-proxy
+//	        Name: gitlab.example.com-tls
+//	    Destinations:
+//	      - Files:
+//	          Cert: /tls-cert.pem
+//	          Key: /tls-priv.pem
+//	          KeyMode: "0600"
+//	          Owner: root
+//	    Hook: gitlab-ctl restart nginx
+type Config struct {
+	Entries []Entry `yaml:"Entries"`
+}
+
+type Entry struct {
+	Name         string        `yaml:"Name"`
+	Source       Source        `yaml:"Source"`
+	Destinations []Destination `yaml:"Destinations"`
+	// Shell command run (sh -c) when at least one destination was updated
+	Hook string `yaml:"Hook"`
+}
+
+// Source has exactly one of Kubernetes, Vault or Files set
+type Source struct {
+	Kubernetes *KubernetesSecret `yaml:"Kubernetes"`
+	Vault      *VaultSecret      `yaml:"Vault"`
+	Files      *Files            `yaml:"Files"`
+}
+
+// Destination has any of Kubernetes, Vault or Files set
+type Destination struct {
+	Kubernetes *KubernetesSecret `yaml:"Kubernetes"`
+	Vault      *VaultSecret      `yaml:"Vault"`
+	Files      *Files            `yaml:"Files"`
+}
+
+type KubernetesSecret struct {
+	Kubeconfig string `yaml:"Kubeconfig"`
+	Context    string `yaml:"Context"`
+	Namespace  string `yaml:"Namespace"`
+	Name       string `yaml:"Name"`
+}
+
+// VaultSecret is KV2 secret <mount>/<path> with tls.crt and tls.key keys
+// (the format written by acme-dns), token is taken from VAULT_TOKEN or
+// ~/.vault-token
+type VaultSecret struct {
+	Addr string `yaml:"Addr"`
+	Path string `yaml:"Path"`
+}
+
+type Files struct {
+	Cert     string `yaml:"Cert"`
+	Key      string `yaml:"Key"`
+	CertMode string `yaml:"CertMode"`
+	KeyMode  string `yaml:"KeyMode"`
+	Owner    string `yaml:"Owner"`
+	Group    string `yaml:"Group"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	for i, entry := range config.Entries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("entry #%d has no Name", i)
+		}
+		if countSet(entry.Source.Kubernetes != nil, entry.Source.Vault != nil, entry.Source.Files != nil) != 1 {
+			return nil, fmt.Errorf("entry %s: Source must have exactly one of Kubernetes, Vault or Files", entry.Name)
+		}
+		if len(entry.Destinations) == 0 {
+			return nil, fmt.Errorf("entry %s has no Destinations", entry.Name)
+		}
+		for j, d := range entry.Destinations {
+			if countSet(d.Kubernetes != nil, d.Vault != nil, d.Files != nil) == 0 {
+				return nil, fmt.Errorf("entry %s: destination #%d is empty", entry.Name, j)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+func countSet(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
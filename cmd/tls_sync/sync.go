@@ -0,0 +1,269 @@
+package tls_sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/sikalabs/slr/internal/file_utils"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slr/internal/vault_utils"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// readSource returns PEM encoded certificate (chain) and private key
+func readSource(s Source) ([]byte, []byte, error) {
+	switch {
+	case s.Kubernetes != nil:
+		clientset, err := getClientset(s.Kubernetes)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret, err := clientset.CoreV1().Secrets(s.Kubernetes.Namespace).Get(context.TODO(), s.Kubernetes.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], nil
+	case s.Vault != nil:
+		data, err := readVault(s.Vault)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []byte(data[corev1.TLSCertKey]), []byte(data[corev1.TLSPrivateKeyKey]), nil
+	default:
+		cert, err := os.ReadFile(s.Files.Cert)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := os.ReadFile(s.Files.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cert, key, nil
+	}
+}
+
+// validate makes sure we never distribute broken or mismatched pair
+func validate(cert, key []byte) error {
+	if len(cert) == 0 || len(key) == 0 {
+		return fmt.Errorf("source has empty certificate or key")
+	}
+	_, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return fmt.Errorf("source certificate and key are not valid pair: %w", err)
+	}
+	return nil
+}
+
+// writeDestination writes cert and key to all targets of destination
+// which differ, returns true if anything was written
+func writeDestination(d Destination, cert, key []byte, dryRun bool) (bool, error) {
+	changed := false
+
+	if d.Files != nil {
+		c, err := writeFiles(d.Files, cert, key, dryRun)
+		if err != nil {
+			return changed, fmt.Errorf("files: %w", err)
+		}
+		changed = changed || c
+	}
+
+	if d.Kubernetes != nil {
+		c, err := writeKubernetes(d.Kubernetes, cert, key, dryRun)
+		if err != nil {
+			return changed, fmt.Errorf("kubernetes %s/%s: %w", d.Kubernetes.Namespace, d.Kubernetes.Name, err)
+		}
+		changed = changed || c
+	}
+
+	if d.Vault != nil {
+		c, err := writeVault(d.Vault, cert, key, dryRun)
+		if err != nil {
+			return changed, fmt.Errorf("vault %s: %w", d.Vault.Path, err)
+		}
+		changed = changed || c
+	}
+
+	return changed, nil
+}
+
+func writeFiles(f *Files, cert, key []byte, dryRun bool) (bool, error) {
+	certMode, err := file_utils.ParseMode(f.CertMode, 0644)
+	if err != nil {
+		return false, err
+	}
+	keyMode, err := file_utils.ParseMode(f.KeyMode, 0600)
+	if err != nil {
+		return false, err
+	}
+
+	uid, gid, err := file_utils.LookupOwner(f.Owner, f.Group)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, file := range []struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}{
+		{f.Cert, cert, certMode},
+		{f.Key, key, keyMode},
+	} {
+		if file.path == "" {
+			continue
+		}
+		current, err := os.ReadFile(file.path)
+		if err == nil && bytes.Equal(current, file.data) {
+			err = fixFileDrift(file.path, file.mode, uid, gid, dryRun)
+			if err != nil {
+				return changed, err
+			}
+			continue
+		}
+		changed = true
+		if dryRun {
+			fmt.Printf("  would write %s\n", file.path)
+			continue
+		}
+		err = file_utils.WriteFileAtomic(file.path, file.data, file.mode, f.Owner, f.Group)
+		if err != nil {
+			return changed, err
+		}
+		fmt.Printf("  written %s\n", file.path)
+	}
+
+	return changed, nil
+}
+
+// fixFileDrift restores mode and owner of file which content is already
+// up to date, it does not count as change (no hook needed)
+func fixFileDrift(path string, mode os.FileMode, uid, gid int, dryRun bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	curUID, curGID := file_utils.FileOwner(info)
+
+	if info.Mode().Perm() != mode.Perm() {
+		if dryRun {
+			fmt.Printf("  would chmod %s %04o\n", path, mode.Perm())
+		} else {
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+			fmt.Printf("  chmod %s %04o\n", path, mode.Perm())
+		}
+	}
+
+	if (uid != -1 && curUID != -1 && uid != curUID) || (gid != -1 && curGID != -1 && gid != curGID) {
+		if dryRun {
+			fmt.Printf("  would chown %s\n", path)
+		} else {
+			if err := os.Chown(path, uid, gid); err != nil {
+				return err
+			}
+			fmt.Printf("  chown %s\n", path)
+		}
+	}
+
+	return nil
+}
+
+func writeKubernetes(k *KubernetesSecret, cert, key []byte, dryRun bool) (bool, error) {
+	clientset, err := getClientset(k)
+	if err != nil {
+		return false, err
+	}
+	secrets := clientset.CoreV1().Secrets(k.Namespace)
+
+	secret, err := secrets.Get(context.TODO(), k.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if dryRun {
+			fmt.Printf("  would create secret %s/%s\n", k.Namespace, k.Name)
+			return true, nil
+		}
+		_, err = secrets.Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: k.Name, Namespace: k.Namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       cert,
+				corev1.TLSPrivateKeyKey: key,
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("  created secret %s/%s\n", k.Namespace, k.Name)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(secret.Data[corev1.TLSCertKey], cert) && bytes.Equal(secret.Data[corev1.TLSPrivateKeyKey], key) {
+		return false, nil
+	}
+	if dryRun {
+		fmt.Printf("  would update secret %s/%s\n", k.Namespace, k.Name)
+		return true, nil
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[corev1.TLSCertKey] = cert
+	secret.Data[corev1.TLSPrivateKeyKey] = key
+	_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("  updated secret %s/%s\n", k.Namespace, k.Name)
+	return true, nil
+}
+
+func writeVault(v *VaultSecret, cert, key []byte, dryRun bool) (bool, error) {
+	client, err := vault_utils.NewClient(v.Addr, "")
+	if err != nil {
+		return false, err
+	}
+
+	current, err := vault_utils.ReadKV2(client, v.Path)
+	if err == nil && current[corev1.TLSCertKey] == string(cert) && current[corev1.TLSPrivateKeyKey] == string(key) {
+		return false, nil
+	}
+	if dryRun {
+		fmt.Printf("  would write vault %s\n", v.Path)
+		return true, nil
+	}
+
+	err = vault_utils.WriteKV2(client, v.Path, map[string]string{
+		corev1.TLSCertKey:       string(cert),
+		corev1.TLSPrivateKeyKey: string(key),
+	})
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("  written vault %s\n", v.Path)
+	return true, nil
+}
+
+func readVault(v *VaultSecret) (map[string]string, error) {
+	client, err := vault_utils.NewClient(v.Addr, "")
+	if err != nil {
+		return nil, err
+	}
+	return vault_utils.ReadKV2(client, v.Path)
+}
+
+func getClientset(k *KubernetesSecret) (kubernetes.Interface, error) {
+	options := &k8s.Options{Kubeconfig: k.Kubeconfig, Context: k.Context}
+	return options.Clientset()
+}
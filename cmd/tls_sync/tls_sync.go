@@ -0,0 +1,105 @@
+package tls_sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/sikalabs/slr/cmd/root"
+	"github.com/spf13/cobra"
+)
+
+const DefaultConfigPath = "/etc/slr/tls-sync.yaml"
+
+var FlagConfig string
+var FlagDryRun bool
+var FlagForceHook bool
+
+func init() {
+	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagConfig, "config", "c", DefaultConfigPath, "Path to tls-sync YAML config")
+	Cmd.Flags().BoolVar(&FlagDryRun, "dry-run", false, "Only print what would be changed")
+	Cmd.Flags().BoolVar(&FlagForceHook, "force-hook", false, "Run hooks even if nothing changed")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "tls-sync",
+	Short: "Sync TLS certificates between Kubernetes secrets, Vault and files, run hook on change",
+	Args:  cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		err := tlsSync(FlagConfig, FlagDryRun, FlagForceHook)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func tlsSync(configPath string, dryRun, forceHook bool) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, entry := range config.Entries {
+		err := syncEntry(entry, dryRun, forceHook)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] Error: %v\n", entry.Name, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries failed", failed, len(config.Entries))
+	}
+	return nil
+}
+
+func syncEntry(entry Entry, dryRun, forceHook bool) error {
+	fmt.Printf("[%s] Reading source\n", entry.Name)
+	cert, key, err := readSource(entry.Source)
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+	if err := validate(cert, key); err != nil {
+		return err
+	}
+
+	// Write all destinations even if some fail, the hook must run for
+	// those which were changed
+	changed := false
+	var errs []error
+	for _, d := range entry.Destinations {
+		c, err := writeDestination(d, cert, key, dryRun)
+		changed = changed || c
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if !changed && !forceHook {
+		if len(errs) == 0 {
+			fmt.Printf("[%s] Certificate not changed\n", entry.Name)
+		}
+		return errors.Join(errs...)
+	}
+	if entry.Hook == "" {
+		return errors.Join(errs...)
+	}
+	if dryRun {
+		fmt.Printf("[%s] Would run hook: %s\n", entry.Name, entry.Hook)
+		return errors.Join(errs...)
+	}
+
+	fmt.Printf("[%s] Running hook: %s\n", entry.Name, entry.Hook)
+	cmd := exec.Command("sh", "-c", entry.Hook)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		errs = append(errs, fmt.Errorf("hook failed: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,287 @@
+package create
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sikalabs/sikalabs-crypt-go/pkg/sikalabs_crypt"
+	"github.com/sikalabs/slr/cmd/training/kubernetes/students"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slr/internal/kv"
+	"github.com/sikalabs/slr/internal/training_encryption_utils"
+	"github.com/spf13/cobra"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const serviceAccountName = "student"
+
+// roleBindingName is fixed, so re-running with another --cluster-role
+// replaces the binding instead of adding a second one
+const roleBindingName = "student"
+
+var FlagK8s *k8s.Options
+var FlagCourse string
+var FlagStudentsFile string
+var FlagClusterRole string
+var FlagDuration time.Duration
+var FlagServer string
+var FlagInsecureSkipTLSVerify bool
+var FlagPassword string
+
+func init() {
+	students.Cmd.AddCommand(Cmd)
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	Cmd.Flags().StringVar(&FlagCourse, "course", "", "Course name, prefix of namespaces and kubeconfig keys")
+	Cmd.MarkFlagRequired("course")
+	Cmd.Flags().StringVarP(&FlagStudentsFile, "students-file", "f", "", "File with students, one per line")
+	Cmd.Flags().StringVar(&FlagClusterRole, "cluster-role", "admin", "ClusterRole bound to the student in their namespace")
+	Cmd.Flags().DurationVar(&FlagDuration, "duration", 72*time.Hour, "Validity of student tokens")
+	Cmd.Flags().StringVar(&FlagServer, "server", "", "API server URL in student kubeconfigs (default: server of the current kubeconfig)")
+	Cmd.Flags().BoolVar(&FlagInsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "Don't include CA in student kubeconfigs, skip TLS verification")
+	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "", "Encryption password of kubeconfigs (default: training encryption password)")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "create [student...]",
+	Short: "Create namespace, ServiceAccount and RoleBinding per student and publish their kubeconfigs",
+	Long: `Create namespace <course>-<student> with ServiceAccount bound to
+--cluster-role, mint a time-bound token (TokenRequest API) and publish
+student's kubeconfig to the key-value storage, encrypted with the
+training encryption password.
+
+Students load it with:
+
+  slr training load-kubeconfig --hostname <course>-<student>
+
+Running it again refreshes the tokens.`,
+	Example: `  slr training kubernetes students create --course k8s-2026-10 alice bob
+  slr training kubernetes students create --course k8s-2026-10 -f students.txt --server https://k8s.sikademo.com:6443`,
+	Run: func(c *cobra.Command, args []string) {
+		err := create(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func create(args []string) error {
+	studentList, err := students.ReadStudents(args, FlagStudentsFile)
+	if err != nil {
+		return err
+	}
+	if len(studentList) == 0 {
+		return fmt.Errorf("no students, use arguments or --students-file")
+	}
+	for _, student := range studentList {
+		namespace := students.Namespace(FlagCourse, student)
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+
+	restConfig, err := FlagK8s.RESTConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := FlagK8s.Clientset()
+	if err != nil {
+		return err
+	}
+	cluster, err := studentCluster(restConfig)
+	if err != nil {
+		return err
+	}
+
+	password := FlagPassword
+	if password == "" {
+		password = training_encryption_utils.GetPasswordOrDie()
+	}
+
+	ctx := context.TODO()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STUDENT\tNAMESPACE\tKEY\tEXPIRES")
+	for _, student := range studentList {
+		namespace := students.Namespace(FlagCourse, student)
+		token, err := setupStudent(ctx, clientset, student, namespace)
+		if err != nil {
+			return fmt.Errorf("student %s: %w", student, err)
+		}
+
+		kubeconfig, err := clientcmd.Write(*studentKubeconfig(cluster, token.Status.Token, FlagCourse+"-"+student, namespace))
+		if err != nil {
+			return err
+		}
+		encrypted, err := sikalabs_crypt.SikaLabsSymmetricEncryptV1(password, string(kubeconfig))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+		}
+		key := students.KVKey(FlagCourse, student)
+		if err := kv.Set(key, encrypted); err != nil {
+			return fmt.Errorf("student %s: failed to publish kubeconfig: %w", student, err)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", student, namespace, key, token.Status.ExpirationTimestamp.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// setupStudent ensures namespace, ServiceAccount and RoleBinding exist
+// and returns new token of the ServiceAccount
+func setupStudent(ctx context.Context, clientset kubernetes.Interface, student, namespace string) (*authenticationv1.TokenRequest, error) {
+	labels := map[string]string{
+		students.LabelManagedBy: students.ManagedBy,
+		students.LabelCourse:    FlagCourse,
+		students.LabelStudent:   student,
+	}
+	meta := metav1.ObjectMeta{Name: namespace, Labels: labels}
+
+	_, err := clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: meta}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		err = checkNamespaceOwner(ctx, clientset, namespace, labels)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create namespace: %w", err)
+	}
+
+	meta = metav1.ObjectMeta{Name: serviceAccountName, Namespace: namespace, Labels: labels}
+	_, err = clientset.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{ObjectMeta: meta}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: roleBindingName, Namespace: namespace, Labels: labels},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     FlagClusterRole,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		}},
+	}
+	if err := ensureRoleBinding(ctx, clientset, roleBinding); err != nil {
+		return nil, err
+	}
+
+	expiration := int64(FlagDuration.Seconds())
+	token, err := clientset.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expiration},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+	return token, nil
+}
+
+// checkNamespaceOwner refuses existing namespace which was not created
+// for this student of this course, it would be handed over to the student
+func checkNamespaceOwner(ctx context.Context, clientset kubernetes.Interface, namespace string, labels map[string]string) error {
+	current, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{students.LabelManagedBy, students.LabelCourse, students.LabelStudent} {
+		if value := labels[key]; current.Labels[key] != value {
+			return fmt.Errorf("namespace %s already exists and is not managed by %s for this student (label %s=%q, expected %q)",
+				namespace, students.ManagedBy, key, current.Labels[key], value)
+		}
+	}
+	return nil
+}
+
+// ensureRoleBinding creates the role binding or updates the existing one,
+// roleRef is immutable, so binding to another role is deleted and created
+func ensureRoleBinding(ctx context.Context, clientset kubernetes.Interface, roleBinding *rbacv1.RoleBinding) error {
+	roleBindings := clientset.RbacV1().RoleBindings(roleBinding.Namespace)
+	current, err := roleBindings.Get(ctx, roleBinding.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = roleBindings.Create(ctx, roleBinding, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create role binding: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get role binding: %w", err)
+	}
+
+	if current.RoleRef != roleBinding.RoleRef {
+		err = roleBindings.Delete(ctx, roleBinding.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete role binding: %w", err)
+		}
+		_, err = roleBindings.Create(ctx, roleBinding, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create role binding: %w", err)
+		}
+		return nil
+	}
+
+	current.Labels = roleBinding.Labels
+	current.Subjects = roleBinding.Subjects
+	_, err = roleBindings.Update(ctx, current, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update role binding: %w", err)
+	}
+	return nil
+}
+
+// studentCluster returns cluster entry from the instructor's config with
+// --server and --insecure-skip-tls-verify applied
+func studentCluster(restConfig *rest.Config) (*clientcmdapi.Cluster, error) {
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = restConfig.Host
+	if FlagServer != "" {
+		cluster.Server = FlagServer
+	}
+	if FlagInsecureSkipTLSVerify {
+		cluster.InsecureSkipTLSVerify = true
+		return cluster, nil
+	}
+
+	cluster.CertificateAuthorityData = restConfig.CAData
+	if len(cluster.CertificateAuthorityData) == 0 && restConfig.CAFile != "" {
+		ca, err := os.ReadFile(restConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA: %w", err)
+		}
+		cluster.CertificateAuthorityData = ca
+	}
+	cluster.InsecureSkipTLSVerify = restConfig.Insecure
+	return cluster, nil
+}
+
+func studentKubeconfig(cluster *clientcmdapi.Cluster, token, name, namespace string) *clientcmdapi.Config {
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Token = token
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = name
+	context.AuthInfo = name
+	context.Namespace = namespace
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = cluster
+	config.AuthInfos[name] = authInfo
+	config.Contexts[name] = context
+	config.CurrentContext = name
+	return config
+}
@@ -0,0 +1,83 @@
+package revoke
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sikalabs/slr/cmd/training/kubernetes/students"
+	"github.com/sikalabs/slr/internal/k8s"
+	"github.com/sikalabs/slr/internal/kv"
+	"github.com/spf13/cobra"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var FlagK8s *k8s.Options
+var FlagCourse string
+
+func init() {
+	students.Cmd.AddCommand(Cmd)
+	FlagK8s = k8s.AddClusterFlags(Cmd)
+	Cmd.Flags().StringVar(&FlagCourse, "course", "", "Course name")
+	Cmd.MarkFlagRequired("course")
+}
+
+var Cmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Delete all student namespaces of the course and their published kubeconfigs",
+	Long: `Delete all namespaces created by "students create" for the course.
+ServiceAccounts are deleted with the namespaces, which invalidates their
+tokens. Published kubeconfigs are overwritten with empty value (the
+key-value storage has no delete).`,
+	Args: cobra.NoArgs,
+	Run: func(c *cobra.Command, args []string) {
+		err := revoke()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func revoke() error {
+	clientset, err := FlagK8s.Clientset()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.TODO()
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: students.Selector(FlagCourse),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	if len(namespaces.Items) == 0 {
+		fmt.Printf("No student namespaces of course %s found\n", FlagCourse)
+		return nil
+	}
+
+	failed := 0
+	for _, namespace := range namespaces.Items {
+		student := namespace.Labels[students.LabelStudent]
+		err := clientset.CoreV1().Namespaces().Delete(ctx, namespace.Name, metav1.DeleteOptions{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to delete namespace %s: %v\n", namespace.Name, err)
+			failed++
+			continue
+		}
+		if student != "" {
+			if err := kv.Set(students.KVKey(FlagCourse, student), ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to clear kubeconfig of %s: %v\n", student, err)
+				failed++
+				continue
+			}
+		}
+		fmt.Printf("Revoked %s (namespace %s)\n", student, namespace.Name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to revoke %d of %d students", failed, len(namespaces.Items))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package students
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sikalabs/slr/cmd/training/kubernetes"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	LabelCourse    = "training.sikalabs.io/course"
+	LabelStudent   = "training.sikalabs.io/student"
+
+	ManagedBy = "slr"
+)
+
+func init() {
+	kubernetes.Cmd.AddCommand(Cmd)
+}
+
+var Cmd = &cobra.Command{
+	Use:     "students",
+	Aliases: []string{"student", "s"},
+	Short:   "Per-student access to a training cluster",
+}
+
+// Namespace returns namespace of the student in the course
+func Namespace(course, student string) string {
+	return course + "-" + student
+}
+
+// KVKey returns key of student's kubeconfig in the key-value storage, it
+// can be loaded by "slr training load-kubeconfig --hostname <course>-<student>"
+func KVKey(course, student string) string {
+	return "kubeconfig-" + course + "-" + student
+}
+
+// Selector returns label selector of all namespaces of the course
+func Selector(course string) string {
+	return LabelManagedBy + "=" + ManagedBy + "," + LabelCourse + "=" + course
+}
+
+// ReadStudents returns students from args and file (one per line, empty
+// lines and # comments are ignored). Names are lower-cased and must be
+// valid DNS labels, they are used in namespace names.
+func ReadStudents(args []string, file string) ([]string, error) {
+	names := append([]string{}, args...)
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			names = append(names, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	students := []string{}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid student name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			students = append(students, name)
+		}
+	}
+	return students, nil
+}
@@ -3,20 +3,27 @@ package load_kubeconfig
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
+	"github.com/sikalabs/sikalabs-crypt-go/pkg/sikalabs_crypt"
 	"github.com/sikalabs/slr/cmd/training"
+	"github.com/sikalabs/slr/internal/kubeconfig_utils"
 	"github.com/sikalabs/slr/internal/kv"
+	"github.com/sikalabs/slr/internal/training_encryption_utils"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 var FlagHostname string
+var FlagPassword string
+var FlagAllowPlaintext bool
 
 func init() {
 	training.Cmd.AddCommand(Cmd)
 	Cmd.Flags().StringVar(&FlagHostname, "hostname", "", "Hostname to load kubeconfig for")
 	Cmd.MarkFlagRequired("hostname")
+	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "", "Decryption password (default: training encryption password)")
+	Cmd.Flags().BoolVar(&FlagAllowPlaintext, "allow-plaintext", false, "Accept unencrypted kubeconfig saved by older versions")
 }
 
 var Cmd = &cobra.Command{
@@ -41,18 +48,52 @@ func loadKubeconfigFromStorage(hostname string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
+	if kubeconfigContent == "" {
+		return fmt.Errorf("no kubeconfig for %s (not saved yet or revoked)", hostname)
+	}
 
-	tmpFile := filepath.Join("/tmp", "kubeconfig-"+hostname)
-	if err := os.WriteFile(tmpFile, []byte(kubeconfigContent), 0600); err != nil {
-		return fmt.Errorf("failed to write kubeconfig to temp file: %w", err)
+	config, err := parseKubeconfig(kubeconfigContent)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.Command("slu", "k8s", "kubeconfig", "add", "-p", tmpFile)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run slu k8s kubeconfig add: %w", err)
+	backup, err := kubeconfig_utils.Merge(config, config.CurrentContext)
+	if err != nil {
+		return err
+	}
+	if backup != "" {
+		fmt.Println("Previous kubeconfig backed up to " + backup)
 	}
 
 	return nil
 }
+
+// parseKubeconfig parses encrypted kubeconfig, plaintext kubeconfigs
+// saved by older versions are accepted only with --allow-plaintext (anyone
+// with access to the storage could have written them)
+func parseKubeconfig(content string) (*clientcmdapi.Config, error) {
+	if config, err := clientcmd.Load([]byte(content)); err == nil && len(config.Contexts) > 0 {
+		if !FlagAllowPlaintext {
+			return nil, fmt.Errorf("kubeconfig is not encrypted, save it again or use --allow-plaintext")
+		}
+		return config, nil
+	}
+
+	password := FlagPassword
+	if password == "" {
+		password = training_encryption_utils.GetPasswordOrDie()
+	}
+	decrypted, err := sikalabs_crypt.SikaLabsSymmetricDecryptV1(password, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt kubeconfig (wrong password?): %w", err)
+	}
+
+	config, err := clientcmd.Load([]byte(decrypted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if len(config.Contexts) == 0 {
+		return nil, fmt.Errorf("kubeconfig has no contexts")
+	}
+	return config, nil
+}
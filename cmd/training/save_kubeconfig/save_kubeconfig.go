@@ -1,19 +1,37 @@
 package save_kubeconfig
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
-	"path/filepath"
 	"regexp"
-	"strings"
+	"time"
 
+	"github.com/sikalabs/sikalabs-crypt-go/pkg/sikalabs_crypt"
 	"github.com/sikalabs/slr/cmd/training"
 	"github.com/sikalabs/slr/internal/kv"
+	"github.com/sikalabs/slr/internal/training_encryption_utils"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+var FlagContext string
+var FlagName string
+var FlagServer string
+var FlagDomain string
+var FlagPassword string
+
 func init() {
 	training.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVar(&FlagContext, "context", "", "Kubeconfig context to save (default: current context)")
+	Cmd.Flags().StringVar(&FlagName, "name", "", "Name of saved context, cluster and user (default: k3d-<hostname> for k3d-default and k3d-training, context name otherwise)")
+	Cmd.Flags().StringVar(&FlagServer, "server", "", "API server URL (default: local server address replaced by <hostname>.<domain>)")
+	Cmd.Flags().StringVar(&FlagDomain, "domain", "sikademo.com", "Domain of the host, used for the default --server")
+	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "", "Encryption password (default: training encryption password)")
 }
 
 var Cmd = &cobra.Command{
@@ -31,38 +49,161 @@ var Cmd = &cobra.Command{
 	},
 }
 
+var k3dDefaultName = regexp.MustCompile(`^k3d-(default|training)$`)
+
 func saveKubeconfigToStorage() error {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
-	kubeconfigPath := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-	if envPath := os.Getenv("KUBECONFIG"); envPath != "" {
-		kubeconfigPath = envPath
-	}
-
-	kubeconfigContent, err := os.ReadFile(kubeconfigPath)
+	config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
 	if err != nil {
 		return fmt.Errorf("failed to read kubeconfig: %w", err)
 	}
+	if FlagContext != "" {
+		config.CurrentContext = FlagContext
+	}
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return err
+	}
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return err
+	}
 
-	modifiedContent := string(kubeconfigContent)
+	name := FlagName
+	if name == "" {
+		name = k3dDefaultName.ReplaceAllString(config.CurrentContext, "k3d-"+hostname)
+	}
+	config = rename(config, name)
+	cluster := config.Clusters[name]
 
-	modifiedContent = strings.ReplaceAll(modifiedContent, "0.0.0.0", hostname+".sikademo.com")
+	server := FlagServer
+	if server == "" {
+		server, err = publicServer(cluster.Server, hostname+"."+FlagDomain)
+		if err != nil {
+			return err
+		}
+	}
+	if len(cluster.CertificateAuthorityData) > 0 && !cluster.InsecureSkipTLSVerify {
+		err := verifyServerName(cluster.Server, server, cluster.CertificateAuthorityData)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "CA can't be used for %s (%v), using insecure-skip-tls-verify\n", server, err)
+			cluster.CertificateAuthorityData = nil
+			cluster.InsecureSkipTLSVerify = true
+		}
+	}
+	cluster.Server = server
 
-	re := regexp.MustCompile(`k3d-(default|training)`)
-	modifiedContent = re.ReplaceAllString(modifiedContent, "k3d-"+hostname)
+	content, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
 
-	certRe := regexp.MustCompile(`certificate-authority-data: [^\n]+`)
-	modifiedContent = certRe.ReplaceAllString(modifiedContent, "insecure-skip-tls-verify: true")
+	password := FlagPassword
+	if password == "" {
+		password = training_encryption_utils.GetPasswordOrDie()
+	}
+	encrypted, err := sikalabs_crypt.SikaLabsSymmetricEncryptV1(password, string(content))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+	}
 
 	key := "kubeconfig-" + hostname
 
-	err = kv.Set(key, modifiedContent)
+	err = kv.Set(key, encrypted)
 	if err != nil {
 		return fmt.Errorf("failed to save kubeconfig: %w", err)
 	}
 
 	return nil
 }
+
+// rename returns minified config with context, cluster and user named name
+func rename(config *clientcmdapi.Config, name string) *clientcmdapi.Config {
+	context := config.Contexts[config.CurrentContext]
+	cluster := config.Clusters[context.Cluster]
+	authInfo := config.AuthInfos[context.AuthInfo]
+
+	context.Cluster = name
+	context.AuthInfo = name
+
+	renamed := clientcmdapi.NewConfig()
+	renamed.Clusters[name] = cluster
+	renamed.AuthInfos[name] = authInfo
+	renamed.Contexts[name] = context
+	renamed.CurrentContext = name
+	return renamed
+}
+
+// publicServer replaces local host (0.0.0.0, localhost, loopback) in the
+// server URL with host, the port is kept
+func publicServer(server, host string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server %q: %w", server, err)
+	}
+	if !isLocal(u.Hostname()) {
+		return server, nil
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else {
+		u.Host = host
+	}
+	return u.String(), nil
+}
+
+func isLocal(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsUnspecified() || ip.IsLoopback())
+}
+
+// verifyServerName connects to the current server and checks its
+// certificate is signed by ca and valid for host of the new server
+func verifyServerName(currentServer, newServer string, ca []byte) error {
+	current, err := url.Parse(currentServer)
+	if err != nil {
+		return err
+	}
+	next, err := url.Parse(newServer)
+	if err != nil {
+		return err
+	}
+
+	addr := current.Host
+	if current.Port() == "" {
+		addr = net.JoinHostPort(current.Hostname(), "443")
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) != nil && net.ParseIP(host).IsUnspecified() {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, &tls.Config{
+		// verified below against the new name
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("invalid CA")
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		DNSName:       next.Hostname(),
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,110 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sikalabs/slr/internal/size_utils"
+)
+
+// uploadResult is JSON response of upload-server, older servers return
+// only the path as text
+type uploadResult struct {
+	ID          string     `json:"id"`
+	Path        string     `json:"path"`
+	Filename    string     `json:"filename"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	Expires     *time.Time `json:"expires"`
+	DeleteToken string     `json:"deleteToken"`
+}
+
+// setUploadHeaders sets headers of a new upload (POST /upload and start
+// of resumable upload)
+func setUploadHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	if FlagExpire != "" {
+		req.Header.Set("X-Upload-Expire", FlagExpire)
+	}
+}
+
+func parseUploadResult(resp *http.Response, body []byte) (*uploadResult, error) {
+	var result uploadResult
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		return &result, nil
+	}
+	result.Path = strings.TrimRight(string(body), "\n")
+	return &result, nil
+}
+
+type listedUpload struct {
+	ID         string     `json:"id"`
+	Filename   string     `json:"filename"`
+	Path       string     `json:"path"`
+	Size       int64      `json:"size"`
+	UploaderIP string     `json:"uploaderIp"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires"`
+}
+
+func list(origin, token string) error {
+	req, err := http.NewRequest(http.MethodGet, origin+"/api/uploads", nil)
+	if err != nil {
+		return err
+	}
+	body, resp, err := do(req, token)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("list failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var uploads []listedUpload
+	if err := json.Unmarshal(body, &uploads); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFILE\tSIZE\tUPLOADER\tCREATED\tEXPIRES\tURL")
+	for _, u := range uploads {
+		expires := "never"
+		if u.Expires != nil {
+			expires = u.Expires.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			u.ID, u.Filename, size_utils.Format(u.Size), u.UploaderIP,
+			u.Created.Local().Format("2006-01-02 15:04"), expires, origin+u.Path)
+	}
+	return w.Flush()
+}
+
+// deleteUpload uses the delete token of the upload if set, server token
+// otherwise
+func deleteUpload(origin, token, id, deleteToken string) error {
+	req, err := http.NewRequest(http.MethodDelete, origin+"/api/uploads/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	if deleteToken != "" {
+		req.Header.Set("X-Delete-Token", deleteToken)
+		token = ""
+	}
+	body, resp, err := do(req, token)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("delete failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	fmt.Printf("Upload %s deleted\n", id)
+	return nil
+}
@@ -0,0 +1,73 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sikalabs/slr/internal/size_utils"
+)
+
+// progress prints progress bar of one upload to stderr, total is -1 for
+// streams of unknown size
+type progress struct {
+	enabled bool
+	name    string
+	total   int64
+	done    int64
+	start   time.Time
+	printed time.Time
+}
+
+func newProgress(enabled bool, name string, total int64) *progress {
+	return &progress{enabled: enabled, name: name, total: total, start: time.Now()}
+}
+
+func (p *progress) Set(done int64) {
+	p.done = done
+	if !p.enabled || time.Since(p.printed) < 200*time.Millisecond {
+		return
+	}
+	p.printed = time.Now()
+	p.print()
+}
+
+func (p *progress) print() {
+	speed := ""
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		speed = size_utils.Format(int64(float64(p.done)/elapsed)) + "/s"
+	}
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s %s %s\033[K", p.name, size_utils.Format(p.done), speed)
+		return
+	}
+	const width = 30
+	filled := int(min(p.done, p.total) * width / p.total)
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %3d%% %s/%s %s\033[K",
+		p.name, strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		min(p.done, p.total)*100/p.total, size_utils.Format(p.done), size_utils.Format(p.total), speed)
+}
+
+func (p *progress) Done() {
+	if !p.enabled {
+		return
+	}
+	p.print()
+	fmt.Fprintln(os.Stderr)
+}
+
+// progressReader reports bytes read from r to the progress
+type progressReader struct {
+	r        io.Reader
+	progress *progress
+	n        int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	r.progress.Set(r.n)
+	return n, err
+}
@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uploadResumable uploads file in chunks of chunkSize, failed chunk is
+// retried (up to retries times in a row) from the offset the server has.
+// It returns the server response and local sha256 of the file.
+func uploadResumable(origin, token string, src source, chunkSize int64, retries int, p *progress) (*uploadResult, string, error) {
+	f, err := os.Open(src.path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	length := src.size
+	if length == 0 {
+		return nil, "", fmt.Errorf("file is empty, upload it without --resumable")
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, length)); err != nil {
+		return nil, "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	req, err := http.NewRequest(http.MethodPost, origin+"/upload/resumable", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("X-Upload-Filename", src.name)
+	req.Header.Set("X-Upload-Length", strconv.FormatInt(length, 10))
+	setUploadHeaders(req)
+	body, resp, err := do(req, token)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, "", fmt.Errorf("upload failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	location := origin + resp.Header.Get("Location")
+
+	var offset int64
+	failures := 0
+	for {
+		result, next, err := uploadChunk(f, location, token, offset, min(chunkSize, length-offset), length)
+		if err == nil && result != nil {
+			p.Set(length)
+			return result, sum, nil
+		}
+		if err == nil {
+			offset, failures = next, 0
+			p.Set(offset)
+			continue
+		}
+
+		var statusErr *chunkStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return nil, "", err
+		}
+		failures++
+		if failures > retries {
+			return nil, "", err
+		}
+		wait := time.Duration(min(failures, 30)) * 2 * time.Second
+		fmt.Fprintf(os.Stderr, "\nChunk failed (%v), retrying in %s\n", err, wait)
+		time.Sleep(wait)
+
+		if current, err := uploadOffset(location, token); err == nil {
+			offset = current
+		}
+	}
+}
+
+// uploadChunk sends size bytes from offset, it returns the upload result
+// after the last chunk or the new offset
+func uploadChunk(f *os.File, location, token string, offset, size, length int64) (*uploadResult, int64, error) {
+	req, err := http.NewRequest(http.MethodPut, location, io.NewSectionReader(f, offset, size))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+size-1, length))
+	req.Header.Set("Accept", "application/json")
+	body, resp, err := do(req, token)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result, err := parseUploadResult(resp, body)
+		return result, length, err
+	case http.StatusNoContent:
+		next, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+		return nil, next, err
+	}
+	return nil, 0, &chunkStatusError{status: resp.StatusCode, message: strings.TrimSpace(string(body))}
+}
+
+// chunkStatusError is a chunk rejected by the server, client errors (4xx,
+// e.g. quota exceeded or expired upload) fail without retries
+type chunkStatusError struct {
+	status  int
+	message string
+}
+
+func (e *chunkStatusError) Error() string {
+	return fmt.Sprintf("chunk failed (%d): %s", e.status, e.message)
+}
+
+// retryable is true for server errors, timeouts and rate limiting, 409
+// Conflict (wrong offset) is fixed by reading the offset again
+func (e *chunkStatusError) retryable() bool {
+	switch e.status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return e.status >= 500
+}
+
+func uploadOffset(location, token string) (int64, error) {
+	req, err := http.NewRequest(http.MethodHead, location, nil)
+	if err != nil {
+		return 0, err
+	}
+	_, resp, err := do(req, token)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("upload not found (%d)", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+func do(req *http.Request, token string) ([]byte, *http.Response, error) {
+	if token != "" {
+		req.Header.Set("X-Upload-Token", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return body, resp, err
+}
@@ -0,0 +1,146 @@
+package upload
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// source is one upload, size is -1 for streams (stdin, directory tar)
+type source struct {
+	arg  string
+	name string
+	path string // regular file or directory, empty for stdin
+	dir  bool
+	size int64
+}
+
+// expandSources resolves arguments: "-" is stdin (named by --name), glob
+// patterns are expanded, directories are uploaded as <dir>.tar.gz
+func expandSources(args []string, stdinName string) ([]source, error) {
+	sources := []source{}
+	stdin := false
+	for _, arg := range args {
+		if arg == "-" {
+			if stdin {
+				return nil, fmt.Errorf("stdin can be used only once")
+			}
+			if stdinName == "" {
+				return nil, fmt.Errorf("--name is required for upload from stdin")
+			}
+			stdin = true
+			sources = append(sources, source{arg: arg, name: stdinName, size: -1})
+			continue
+		}
+
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			paths, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+		}
+		for _, path := range paths {
+			stat, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case stat.IsDir():
+				name := filepath.Base(filepath.Clean(path)) + ".tar.gz"
+				sources = append(sources, source{arg: path, name: name, path: path, dir: true, size: -1})
+			case stat.Mode().IsRegular():
+				sources = append(sources, source{arg: path, name: filepath.Base(path), path: path, size: stat.Size()})
+			default:
+				return nil, fmt.Errorf("%s is not a regular file or directory", path)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// open returns content of the source, directory is tarred on the fly
+func (s source) open() (io.ReadCloser, error) {
+	switch {
+	case s.path == "":
+		return io.NopCloser(os.Stdin), nil
+	case s.dir:
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeTarGz(pw, s.path))
+		}()
+		return pr, nil
+	}
+	return os.Open(s.path)
+}
+
+// writeTarGz writes regular files, directories and symlinks of dir,
+// paths in the archive start with base name of dir
+func writeTarGz(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(filepath.Clean(dir))
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			// sockets, devices, ...
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -1,29 +1,93 @@
 package upload
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/size_utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+const (
+	OutputPlain = "plain"
+	OutputJSON  = "json"
+)
+
+var FlagResumable bool
+var FlagChunkSize string
+var FlagRetries int
+var FlagExpire string
+var FlagList bool
+var FlagDelete string
+var FlagDeleteToken string
+var FlagName string
+var FlagOutput string
+var FlagNoProgress bool
+var FlagClipboard bool
+var FlagQR bool
+
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagResumable, "resumable", "r", false, "Upload in chunks, resume after network failures (regular files only)")
+	Cmd.Flags().StringVar(&FlagChunkSize, "chunk-size", "8M", "Chunk size of resumable upload")
+	Cmd.Flags().IntVar(&FlagRetries, "retries", 10, "Retries of failed chunk of resumable upload")
+	Cmd.Flags().StringVarP(&FlagExpire, "expire", "e", "", "Delete the upload after, e.g. 7d or 12h (default: server default)")
+	Cmd.Flags().BoolVarP(&FlagList, "list", "l", false, "List uploads (all with server token, own with user token)")
+	Cmd.Flags().StringVar(&FlagDelete, "delete", "", "Delete upload by ID")
+	Cmd.Flags().StringVar(&FlagDeleteToken, "delete-token", "", "Delete token of the upload (default: server token)")
+	Cmd.Flags().StringVarP(&FlagName, "name", "n", "", "File name of upload from stdin (-)")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", OutputPlain, "Output format (plain, json)")
+	Cmd.Flags().BoolVar(&FlagNoProgress, "no-progress", false, "Don't show progress bar (it's shown only on terminal)")
+	Cmd.Flags().BoolVarP(&FlagClipboard, "clipboard", "c", false, "Copy URLs to clipboard")
+	Cmd.Flags().BoolVar(&FlagQR, "qr", false, "Print QR codes of URLs to stderr")
+	Cmd.MarkFlagsMutuallyExclusive("list", "delete")
 }
 
 var Cmd = &cobra.Command{
-	Use:   "upload <file>",
-	Short: "Upload a file to the upload server",
-	Args:  cobra.ExactArgs(1),
+	Use:   "upload <file|dir|glob|->...",
+	Short: "Upload files to the upload server",
+	Example: `  slr upload video.mp4 --expire 7d
+  slr upload 'recordings/*.mp4' slides/ --qr
+  pg_dump app | slr upload - --name app.sql
+  slr upload --list
+  slr upload --delete 1792428763_s5fq8jxs --delete-token <token>`,
+	Args: func(c *cobra.Command, args []string) error {
+		if FlagList || FlagDelete != "" {
+			return cobra.NoArgs(c, args)
+		}
+		return cobra.MinimumNArgs(1)(c, args)
+	},
 	Run: func(c *cobra.Command, args []string) {
-		upload(args[0])
+		origin := readConfig("SLR_UPLOAD_SERVER_ORIGIN", "/etc/SLR_UPLOAD_SERVER_ORIGIN")
+		if origin == "" {
+			fmt.Fprintln(os.Stderr, "Error: SLR_UPLOAD_SERVER_ORIGIN env var or /etc/SLR_UPLOAD_SERVER_ORIGIN file not set")
+			os.Exit(1)
+		}
+		token := readConfig("SLR_UPLOAD_SERVER_TOKEN", "/etc/SLR_UPLOAD_SERVER_TOKEN")
+
+		var err error
+		switch {
+		case FlagList:
+			err = list(origin, token)
+		case FlagDelete != "":
+			err = deleteUpload(origin, token, FlagDelete, FlagDeleteToken)
+		default:
+			err = upload(origin, token, args)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	},
 }
 
@@ -37,58 +101,152 @@ func readConfig(envVar, filePath string) string {
 	return ""
 }
 
-func upload(filePath string) {
-	origin := readConfig("SLR_UPLOAD_SERVER_ORIGIN", "/etc/SLR_UPLOAD_SERVER_ORIGIN")
-	if origin == "" {
-		fmt.Fprintln(os.Stderr, "Error: SLR_UPLOAD_SERVER_ORIGIN env var or /etc/SLR_UPLOAD_SERVER_ORIGIN file not set")
-		os.Exit(1)
+// uploaded is one line of JSON output
+type uploaded struct {
+	File        string     `json:"file"`
+	URL         string     `json:"url"`
+	ID          string     `json:"id,omitempty"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	DeleteToken string     `json:"deleteToken,omitempty"`
+}
+
+func upload(origin, token string, args []string) error {
+	if FlagOutput != OutputPlain && FlagOutput != OutputJSON {
+		return fmt.Errorf("unknown output %q (plain, json)", FlagOutput)
 	}
+	sources, err := expandSources(args, FlagName)
+	if err != nil {
+		return err
+	}
+	var chunkSize int64
+	if FlagResumable {
+		chunkSize, err = size_utils.Parse(FlagChunkSize)
+		if err != nil || chunkSize <= 0 {
+			return fmt.Errorf("invalid --chunk-size %q", FlagChunkSize)
+		}
+	}
+	showProgress := !FlagNoProgress && term.IsTerminal(int(os.Stderr.Fd()))
 
-	token := readConfig("SLR_UPLOAD_SERVER_TOKEN", "/etc/SLR_UPLOAD_SERVER_TOKEN")
+	results := []uploaded{}
+	for _, src := range sources {
+		p := newProgress(showProgress, src.name, src.size)
+		var result *uploadResult
+		var sum string
+		if FlagResumable && src.size >= 0 {
+			result, sum, err = uploadResumable(origin, token, src, chunkSize, FlagRetries, p)
+		} else {
+			result, sum, err = uploadMultipart(origin, token, src, p)
+		}
+		p.Done()
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.arg, err)
+		}
+		// older servers don't return the checksum
+		if result.SHA256 != "" && result.SHA256 != sum {
+			return fmt.Errorf("%s: checksum mismatch, local sha256 %s, server %s", src.arg, sum, result.SHA256)
+		}
 
-	f, err := os.Open(filePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
-		os.Exit(1)
+		r := uploaded{
+			File:        src.arg,
+			URL:         origin + result.Path,
+			ID:          result.ID,
+			Size:        result.Size,
+			SHA256:      sum,
+			Expires:     result.Expires,
+			DeleteToken: result.DeleteToken,
+		}
+		results = append(results, r)
+		if FlagOutput == OutputPlain {
+			printPlain(r)
+		}
 	}
-	defer f.Close()
 
-	var buf bytes.Buffer
-	w := multipart.NewWriter(&buf)
-	fw, err := w.CreateFormFile("file", filepath.Base(filePath))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating form: %v\n", err)
-		os.Exit(1)
+	if FlagOutput == OutputJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	}
+
+	urls := []string{}
+	for _, r := range results {
+		urls = append(urls, r.URL)
+	}
+	if FlagQR {
+		for _, url := range urls {
+			fmt.Fprintln(os.Stderr, url)
+			if err := printQR(os.Stderr, url); err != nil {
+				return err
+			}
+		}
 	}
-	if _, err := io.Copy(fw, f); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		os.Exit(1)
+	if FlagClipboard {
+		if err := copyToClipboard(strings.Join(urls, "\n")); err != nil {
+			return fmt.Errorf("clipboard: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Copied to clipboard")
 	}
-	w.Close()
+	return nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, origin+"/upload", &buf)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
+// printPlain prints URL to stdout and details to stderr, so stdout can
+// be piped
+func printPlain(r uploaded) {
+	fmt.Println(r.URL)
+	if r.DeleteToken != "" {
+		fmt.Fprintf(os.Stderr, "ID: %s\nDelete token: %s\n", r.ID, r.DeleteToken)
 	}
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	if token != "" {
-		req.Header.Set("X-Upload-Token", token)
+	if r.Expires != nil {
+		fmt.Fprintf(os.Stderr, "Expires: %s\n", r.Expires.Local().Format("2006-01-02 15:04:05"))
 	}
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// uploadMultipart streams the source as multipart form through io.Pipe,
+// it returns the server response and local sha256 of the content
+func uploadMultipart(origin, token string, src source, p *progress) (*uploadResult, string, error) {
+	content, err := src.open()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error uploading: %v\n", err)
-		os.Exit(1)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
+	defer content.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "Upload failed (%d): %s\n", resp.StatusCode, body)
-		os.Exit(1)
+	hash := sha256.New()
+	reader := &progressReader{r: io.TeeReader(content, hash), progress: p}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	w := multipart.NewWriter(pw)
+	go func() {
+		fw, err := w.CreateFormFile("file", src.name)
+		if err == nil {
+			_, err = io.Copy(fw, reader)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, origin+"/upload", pr)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating request: %w", err)
 	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	setUploadHeaders(req)
 
-	fmt.Printf("%s%s", origin, strings.TrimRight(string(body), "\n"))
-	fmt.Println()
+	body, resp, err := do(req, token)
+	if err != nil {
+		return nil, "", fmt.Errorf("uploading: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("upload failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	result, err := parseUploadResult(resp, body)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, hex.EncodeToString(hash.Sum(nil)), nil
 }
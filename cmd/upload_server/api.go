@@ -0,0 +1,235 @@
+package upload_server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sikalabs/slr/internal/time_utils"
+)
+
+// resumableTTL is age of unfinished resumable uploads removed by janitor
+const resumableTTL = 24 * time.Hour
+
+// uploadResponse is JSON response of a finished upload
+type uploadResponse struct {
+	ID          string     `json:"id"`
+	Path        string     `json:"path"`
+	Filename    string     `json:"filename"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	DeleteToken string     `json:"deleteToken"`
+}
+
+// parseExpire parses expiration flag, empty or "0" means never
+func parseExpire(s string) (time.Duration, error) {
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	d, err := time_utils.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+	return d, nil
+}
+
+// uploadExpiry returns expiration time of a new upload from
+// X-Upload-Expire, --default-expire and --max-expire
+func uploadExpiry(header string) (*time.Time, error) {
+	expire := defaultExpire
+	if header != "" {
+		var err error
+		expire, err = parseExpire(header)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid X-Upload-Expire: %v", err)
+		}
+	}
+	if maxExpire > 0 {
+		if expire == 0 && header == "" {
+			expire = maxExpire
+		}
+		if expire == 0 || expire > maxExpire {
+			return nil, fmt.Errorf("X-Upload-Expire exceeds maximum %s", maxExpire)
+		}
+	}
+	if expire == 0 {
+		return nil, nil
+	}
+	expires := time.Now().Add(expire).Truncate(time.Second)
+	return &expires, nil
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// respondUpload adds the upload to the index and returns its path (or
+// JSON with Accept: application/json) with id and delete token headers,
+// upload over quota of its user is removed. release drops reservation of
+// finished resumable upload (nil for others), see index.Add.
+func respondUpload(w http.ResponseWriter, r *http.Request, u *Upload, release func()) {
+	reserved := func() int64 { return resumableReserved(u.User) }
+	deleteToken, err := uploads.Add(u, quotaOf(u.User), reserved, release)
+	if errors.Is(err, errQuotaExceeded) {
+		os.RemoveAll(u.dir(FlagDataDir))
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to save index: %v\n", err)
+		http.Error(w, "Error saving upload metadata", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Upload-Id", u.ID)
+	w.Header().Set("X-Delete-Token", deleteToken)
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(uploadResponse{
+			ID:          u.ID,
+			Path:        u.Path,
+			Filename:    u.Filename,
+			Size:        u.Size,
+			SHA256:      u.SHA256,
+			Expires:     u.Expires,
+			DeleteToken: deleteToken,
+		})
+		return
+	}
+	fmt.Fprintf(w, "%s\n", u.Path)
+}
+
+// listUploadsHandler lists all uploads for --token and own uploads for
+// users of --tokens-file, anonymous listing is disabled
+func listUploadsHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authorized(w, r)
+	if !ok {
+		return
+	}
+	if !caller.Admin && caller.Name == "" {
+		http.Error(w, "Listing is disabled, server has no --token", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	list := []Upload{}
+	for _, u := range uploads.List() {
+		if u.expired(now) || (!caller.Admin && u.User != caller.Name) {
+			continue
+		}
+		item := *u
+		item.DeleteTokenHash = ""
+		list = append(list, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// deleteUploadHandler accepts delete token of the upload, --token or
+// token of the uploader
+func deleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	u, ok := uploads.Get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	caller := authenticate(r)
+	permitted := caller != nil && (caller.Admin || (caller.Name != "" && caller.Name == u.User))
+	if !permitted && !u.validDeleteToken(r.Header.Get("X-Delete-Token")) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := uploads.Delete(FlagDataDir, id); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to delete %s: %v\n", id, err)
+		http.Error(w, "Error deleting upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// meResponse is response of /api/me, sizes are in bytes, 0 for unlimited
+type meResponse struct {
+	User     string `json:"user"`
+	Admin    bool   `json:"admin"`
+	Quota    int64  `json:"quota"`
+	Used     int64  `json:"used"`
+	Reserved int64  `json:"reserved"`
+	MaxSize  int64  `json:"maxSize"`
+	Uploads  int    `json:"uploads"`
+}
+
+// meHandler returns quota and usage of the caller, used space is
+// accounted by uploads in the index including expired ones not yet
+// deleted by janitor, reserved is length of unfinished resumable uploads
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authorized(w, r)
+	if !ok {
+		return
+	}
+	me := meResponse{
+		User:     caller.Name,
+		Admin:    caller.Admin,
+		Quota:    caller.Quota,
+		Reserved: resumableReserved(caller.Name),
+		MaxSize:  maxSize,
+	}
+	if caller.MaxSize > 0 && (me.MaxSize == 0 || caller.MaxSize < me.MaxSize) {
+		me.MaxSize = caller.MaxSize
+	}
+	for _, u := range uploads.List() {
+		if u.User == caller.Name {
+			me.Used += u.Size
+			me.Uploads++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(me)
+}
+
+// runJanitor deletes expired uploads and abandoned resumable uploads
+func runJanitor(interval time.Duration) {
+	for {
+		now := time.Now()
+		for _, id := range uploads.Expired(now) {
+			if err := uploads.Delete(FlagDataDir, id); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to delete expired %s: %v\n", id, err)
+				continue
+			}
+			fmt.Printf("Deleted expired upload %s\n", id)
+		}
+
+		dirs, _ := os.ReadDir(filepath.Join(FlagDataDir, resumableDir))
+		for _, dir := range dirs {
+			path := filepath.Join(FlagDataDir, resumableDir, dir.Name())
+			// data is modified by every chunk
+			var modTime time.Time
+			if stat, err := os.Stat(filepath.Join(path, "data")); err == nil {
+				modTime = stat.ModTime()
+			} else if info, err := dir.Info(); err == nil {
+				modTime = info.ModTime()
+			}
+			if now.Sub(modTime) > resumableTTL {
+				os.RemoveAll(path)
+				resumableLocks.Delete(dir.Name())
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
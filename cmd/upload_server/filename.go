@@ -0,0 +1,48 @@
+package upload_server
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const maxFilenameLength = 200
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeFilename returns base name of the uploaded file (both / and \
+// are treated as separators) with characters other than A-Z, a-z, 0-9,
+// ".", "_" and "-" replaced by "_" and without leading dots, so it can't
+// escape the upload directory or create a hidden file
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = unsafeFilenameChars.ReplaceAllString(name, "_")
+	name = strings.TrimLeft(name, ".")
+	if len(name) > maxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 20 {
+			ext = ""
+		}
+		name = name[:maxFilenameLength-len(ext)] + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// allowedExtension checks extension of the name against --allowed-ext,
+// empty list allows everything
+func allowedExtension(name string) bool {
+	if len(FlagAllowedExt) == 0 {
+		return true
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	for _, allowed := range FlagAllowedExt {
+		if ext == strings.TrimPrefix(strings.ToLower(allowed), ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package upload_server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sikalabs/slr/internal/file_utils"
+)
+
+// indexFile is hidden from /files/ by filesHandler
+const indexFile = ".index.json"
+
+// Upload is metadata of one upload, ID is name of its directory in
+// directory of the user (--data-dir for admin and anonymous uploads)
+type Upload struct {
+	ID         string     `json:"id"`
+	User       string     `json:"user,omitempty"`
+	Filename   string     `json:"filename"`
+	Path       string     `json:"path"`
+	Size       int64      `json:"size"`
+	SHA256     string     `json:"sha256"`
+	UploaderIP string     `json:"uploaderIp"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires,omitempty"`
+
+	// DeleteTokenHash is sha256 of the delete token, the token itself is
+	// returned only in the upload response
+	DeleteTokenHash string `json:"deleteTokenHash,omitempty"`
+}
+
+func (u *Upload) expired(now time.Time) bool {
+	return u.Expires != nil && now.After(*u.Expires)
+}
+
+func (u *Upload) dir(dataDir string) string {
+	return filepath.Join(dataDir, u.User, u.ID)
+}
+
+// index keeps metadata of all uploads in --data-dir/.index.json
+type index struct {
+	mu      sync.Mutex
+	path    string
+	uploads map[string]*Upload
+}
+
+func loadIndex(dataDir string) (*index, error) {
+	idx := &index{
+		path:    filepath.Join(dataDir, indexFile),
+		uploads: map[string]*Upload{},
+	}
+	data, err := os.ReadFile(idx.path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var uploads []*Upload
+	if err := json.Unmarshal(data, &uploads); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", idx.path, err)
+	}
+	for _, u := range uploads {
+		idx.uploads[u.ID] = u
+	}
+	return idx, nil
+}
+
+// saveLocked writes the index atomically, caller holds mu
+func (idx *index) saveLocked() error {
+	data, err := json.MarshalIndent(idx.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return file_utils.WriteFileAtomic(idx.path, data, 0600, "", "")
+}
+
+func (idx *index) listLocked() []*Upload {
+	uploads := make([]*Upload, 0, len(idx.uploads))
+	for _, u := range idx.uploads {
+		uploads = append(uploads, u)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Created.Before(uploads[j].Created)
+	})
+	return uploads
+}
+
+func (idx *index) List() []*Upload {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.listLocked()
+}
+
+// Add stores the upload and returns its new delete token, it returns
+// errQuotaExceeded if the upload doesn't fit into quota of its user (0 for
+// unlimited) with reserved space of unfinished resumable uploads,
+// concurrent uploads are checked here under the lock. Finished resumable
+// upload drops its own reservation with release (nil for others) under
+// the lock too, so no other upload can take the space in between.
+func (idx *index) Add(u *Upload, quota int64, reserved func() int64, release func()) (string, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	u.DeleteTokenHash = hashToken(token)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if release != nil {
+		release()
+	}
+	if quota > 0 && idx.usageLocked(u.User)+reserved()+u.Size > quota {
+		return "", errQuotaExceeded
+	}
+	idx.uploads[u.ID] = u
+	return token, idx.saveLocked()
+}
+
+// Usage returns total size of uploads of the user
+func (idx *index) Usage(user string) int64 {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.usageLocked(user)
+}
+
+func (idx *index) usageLocked(user string) int64 {
+	var usage int64
+	for _, u := range idx.uploads {
+		if u.User == user {
+			usage += u.Size
+		}
+	}
+	return usage
+}
+
+func (idx *index) Get(id string) (*Upload, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	u, ok := idx.uploads[id]
+	return u, ok
+}
+
+// Delete removes the upload directory and its metadata
+func (idx *index) Delete(dataDir, id string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	u, ok := idx.uploads[id]
+	if !ok {
+		return nil
+	}
+	if err := os.RemoveAll(u.dir(dataDir)); err != nil {
+		return err
+	}
+	delete(idx.uploads, id)
+	return idx.saveLocked()
+}
+
+// Expired returns IDs of uploads expired at now
+func (idx *index) Expired(now time.Time) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	ids := []string{}
+	for id, u := range idx.uploads {
+		if u.expired(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validDeleteToken compares the token with the stored hash in constant time
+func (u *Upload) validDeleteToken(token string) bool {
+	if token == "" || u.DeleteTokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(u.DeleteTokenHash)) == 1
+}
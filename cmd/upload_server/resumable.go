@@ -0,0 +1,281 @@
+package upload_server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumableDir keeps unfinished uploads in --data-dir, it's hidden from
+// /files/ by filesHandler
+const resumableDir = ".resumable"
+
+var resumableIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+var contentRangeRegexp = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// resumableLocks serializes chunks of one upload
+var resumableLocks sync.Map
+
+type resumableInfo struct {
+	User       string     `json:"user,omitempty"`
+	Filename   string     `json:"filename"`
+	Length     int64      `json:"length"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires,omitempty"`
+	UploaderIP string     `json:"uploaderIp"`
+}
+
+func resumablePath(id string) string {
+	return filepath.Join(FlagDataDir, resumableDir, id)
+}
+
+// resumableReserved returns total length of unfinished resumable uploads
+// of the user, they reserve quota from their start
+func resumableReserved(user string) int64 {
+	var reserved int64
+	dirs, _ := os.ReadDir(filepath.Join(FlagDataDir, resumableDir))
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(FlagDataDir, resumableDir, dir.Name(), "info.json"))
+		if err != nil {
+			continue
+		}
+		var info resumableInfo
+		if json.Unmarshal(data, &info) == nil && info.User == user {
+			reserved += info.Length
+		}
+	}
+	return reserved
+}
+
+// resumableCreateHandler starts a resumable upload, the response contains
+// its URL path in Location header and body
+func resumableCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	owner, ok := authorized(w, r)
+	if !ok {
+		return
+	}
+
+	filename := sanitizeFilename(r.Header.Get("X-Upload-Filename"))
+	if !allowedExtension(filename) {
+		http.Error(w, "File extension not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("X-Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Invalid X-Upload-Length", http.StatusBadRequest)
+		return
+	}
+	// the length is reserved in quota until the upload is finished, then
+	// quota is checked again
+	limit, err := uploadLimit(owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if limit > 0 && length > limit {
+		uploadError(w, &http.MaxBytesError{Limit: limit}, "", 0, limit)
+		return
+	}
+	expires, err := uploadExpiry(r.Header.Get("X-Upload-Expire"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	info, _ := json.Marshal(resumableInfo{
+		User:       owner.Name,
+		Filename:   filename,
+		Length:     length,
+		Created:    time.Now(),
+		Expires:    expires,
+		UploaderIP: remoteIP(r),
+	})
+	dir := resumablePath(id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		http.Error(w, "Error creating directory", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "info.json"), info, 0600); err != nil {
+		http.Error(w, "Error creating upload", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), nil, 0600); err != nil {
+		http.Error(w, "Error creating upload", http.StatusInternalServerError)
+		return
+	}
+
+	location := "/upload/resumable/" + id
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s\n", location)
+}
+
+// resumableHandler returns offset of the upload (HEAD, GET) or appends
+// a chunk (PUT). Chunk has to start at the current offset, if the
+// connection breaks, the received part is kept and the client continues
+// from the offset returned by HEAD. Only the user who started the upload
+// (or --token) can continue it.
+func resumableHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authorized(w, r)
+	if !ok {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/upload/resumable/")
+	if !resumableIDRegexp.MatchString(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// unknown IDs don't get a lock, the map would grow with every request
+	dir := resumablePath(id)
+	if _, err := os.Stat(filepath.Join(dir, "info.json")); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	lock, _ := resumableLocks.LoadOrStore(id, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	data, err := os.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil {
+		// finished or expired while waiting for the lock
+		resumableLocks.Delete(id)
+		http.NotFound(w, r)
+		return
+	}
+	var info resumableInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		http.Error(w, "Invalid upload", http.StatusInternalServerError)
+		return
+	}
+	if !caller.Admin && caller.Name != info.User {
+		http.NotFound(w, r)
+		return
+	}
+	dataPath := filepath.Join(dir, "data")
+	stat, err := os.Stat(dataPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	offset := stat.Size()
+
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		return
+	case http.MethodPut:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, end, length, ok := parseContentRange(r.Header.Get("Content-Range"))
+	if !ok || length != info.Length || end >= length {
+		http.Error(w, "Invalid Content-Range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if start != offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		http.Error(w, fmt.Sprintf("Chunk must start at offset %d", offset), http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		http.Error(w, "Error writing file", http.StatusInternalServerError)
+		return
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r.Body, end-start+1))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	offset += n
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if copyErr != nil {
+		http.Error(w, "Error writing chunk", http.StatusInternalServerError)
+		return
+	}
+
+	if offset < info.Length {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	u, err := finishResumable(dataPath, info)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondUpload(w, r, u, func() { os.RemoveAll(dir) })
+	resumableLocks.Delete(id)
+}
+
+// finishResumable moves completed upload to a new upload directory of
+// the user
+func finishResumable(dataPath string, info resumableInfo) (*Upload, error) {
+	f, err := os.Open(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file")
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file")
+	}
+
+	dirName, dirPath, err := newUploadDir(info.User)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating directory")
+	}
+	if err := os.Rename(dataPath, filepath.Join(dirPath, info.Filename)); err != nil {
+		os.RemoveAll(dirPath)
+		return nil, fmt.Errorf("Error saving file")
+	}
+	os.Chmod(filepath.Join(dirPath, info.Filename), 0644)
+
+	return &Upload{
+		ID:         dirName,
+		User:       info.User,
+		Filename:   info.Filename,
+		Path:       filesPath(info.User, dirName, info.Filename),
+		Size:       info.Length,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		UploaderIP: info.UploaderIP,
+		Created:    time.Now(),
+		Expires:    info.Expires,
+	}, nil
+}
+
+func parseContentRange(s string) (int64, int64, int64, bool) {
+	m := contentRangeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, 0, false
+	}
+	start, _ := strconv.ParseInt(m[1], 10, 64)
+	end, _ := strconv.ParseInt(m[2], 10, 64)
+	length, _ := strconv.ParseInt(m[3], 10, 64)
+	return start, end, length, start <= end
+}
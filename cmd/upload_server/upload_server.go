@@ -1,34 +1,114 @@
 package upload_server
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sikalabs/slr/cmd/root"
+	"github.com/sikalabs/slr/internal/httpserver"
+	"github.com/sikalabs/slr/internal/size_utils"
 	"github.com/sikalabs/slr/version"
 	"github.com/spf13/cobra"
 )
 
+// multipartOverhead is allowed on top of --max-size for multipart headers
+// and boundaries
+const multipartOverhead = 1 << 20
+
 var FlagDataDir string
 var FlagToken string
+var FlagTokensFile string
+var FlagMaxSize string
+var FlagAllowedExt []string
+var FlagDefaultExpire string
+var FlagMaxExpire string
+var FlagServer *httpserver.Options
+
+var maxSize int64
+var defaultExpire time.Duration
+var maxExpire time.Duration
+var uploads *index
 
 func init() {
 	root.Cmd.AddCommand(Cmd)
+	// no read and write timeouts, uploads and downloads of large files
+	// take long
+	FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{})
 	Cmd.Flags().StringVar(&FlagDataDir, "data-dir", "", "Directory to store uploaded files (required)")
 	Cmd.MarkFlagRequired("data-dir")
-	Cmd.Flags().StringVar(&FlagToken, "token", "", "Optional token to require for uploads")
+	Cmd.Flags().StringVar(&FlagToken, "token", "", "Optional admin token to require for uploads")
+	Cmd.Flags().StringVar(&FlagTokensFile, "tokens-file", "", "YAML file with per-user tokens, quotas and max sizes (reloaded on SIGHUP)")
+	Cmd.Flags().StringVar(&FlagMaxSize, "max-size", "0", "Maximum size of uploaded file, e.g. 500M or 10G (0 for unlimited)")
+	Cmd.Flags().StringSliceVar(&FlagAllowedExt, "allowed-ext", nil, "Allowed file extensions, e.g. mp4,mkv,pdf (default: all)")
+	Cmd.Flags().StringVar(&FlagDefaultExpire, "default-expire", "", "Expiration of uploads without X-Upload-Expire, e.g. 7d (default: never)")
+	Cmd.Flags().StringVar(&FlagMaxExpire, "max-expire", "", "Maximum expiration of uploads, e.g. 30d (default: unlimited)")
 }
 
 var Cmd = &cobra.Command{
 	Use:   "upload-server",
 	Short: "Simple file upload server",
-	Args:  cobra.NoArgs,
+	Long: `Simple file upload server.
+
+Upload file as multipart form field "file":
+
+  curl -F file=@video.mp4 -H "X-Upload-Token: $TOKEN" http://127.0.0.1:8000/upload
+
+Large files can be uploaded in chunks and resumed after a failure:
+
+  POST /upload/resumable       X-Upload-Filename, X-Upload-Length -> Location of the upload
+  HEAD /upload/resumable/<id>  -> Upload-Offset (bytes already received)
+  PUT  /upload/resumable/<id>  Content-Range: bytes <start>-<end>/<length>
+
+The last chunk returns path of the file as POST /upload does.
+
+Uploads expire after X-Upload-Expire (e.g. 7d) or --default-expire.
+Upload response contains X-Upload-Id and X-Delete-Token headers (JSON
+with "Accept: application/json"), the delete token or --token is
+required for:
+
+  GET    /api/uploads       list uploads (all with --token, own with user token)
+  DELETE /api/uploads/<id>  X-Delete-Token or X-Upload-Token
+
+Users of --tokens-file upload to their own directories within quota,
+unfinished resumable uploads reserve their whole length:
+
+  Tokens:
+    - Token: 6f1c...
+      User: alice
+      Quota: 5G
+      MaxSize: 1G
+
+  GET    /api/me            user, quota, used and reserved space and max file size
+
+Send SIGHUP to reload the tokens file.`,
+	Args: cobra.NoArgs,
 	Run: func(c *cobra.Command, args []string) {
+		var err error
+		maxSize, err = size_utils.Parse(FlagMaxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --max-size: %v\n", err)
+			os.Exit(1)
+		}
+		defaultExpire, err = parseExpire(FlagDefaultExpire)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --default-expire: %v\n", err)
+			os.Exit(1)
+		}
+		maxExpire, err = parseExpire(FlagMaxExpire)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --max-expire: %v\n", err)
+			os.Exit(1)
+		}
 		runServer()
 	},
 }
@@ -43,67 +123,202 @@ func randomString(n int) string {
 }
 
 func runServer() {
+	var err error
+	uploads, err = loadIndex(FlagDataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if FlagTokensFile != "" {
+		tokens, err := loadTokens(FlagTokensFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		users.Store(&tokens)
+		go reloadTokensOnSIGHUP(FlagTokensFile)
+	}
+	go runJanitor(time.Minute)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", indexHandler)
 	mux.HandleFunc("/upload", uploadHandler)
-	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(FlagDataDir))))
+	mux.HandleFunc("/upload/resumable", resumableCreateHandler)
+	mux.HandleFunc("/upload/resumable/", resumableHandler)
+	mux.HandleFunc("GET /api/uploads", listUploadsHandler)
+	mux.HandleFunc("DELETE /api/uploads/{id}", deleteUploadHandler)
+	mux.HandleFunc("GET /api/me", meHandler)
+	mux.Handle("/files/", http.StripPrefix("/files/", filesHandler(http.FileServer(http.Dir(FlagDataDir)))))
 
-	fmt.Println("Listen on 0.0.0.0:8000, see http://127.0.0.1:8000")
-	http.ListenAndServe(":8000", mux)
+	if err := FlagServer.ListenAndServe(mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "slr upload-server, slr version %s\n", version.Version)
 }
 
+// filesHandler serves only files of existing uploads, paths with any
+// component starting with "." (index, unfinished resumable uploads),
+// directories (no listing) and expired uploads return 404. Paths are
+// <id>/<file> or <user>/<id>/<file>.
+func filesHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		for _, part := range parts {
+			if strings.HasPrefix(part, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		for _, id := range parts[:min(len(parts), 2)] {
+			if u, ok := uploads.Get(id); ok && u.expired(time.Now()) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		stat, err := os.Stat(filepath.Join(FlagDataDir, filepath.FromSlash(path.Clean("/"+r.URL.Path))))
+		if err != nil || stat.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newUploadDir creates directory <unix time>_<random> for one upload in
+// directory of the user
+func newUploadDir(userName string) (string, string, error) {
+	dirName := fmt.Sprintf("%d_%s", time.Now().Unix(), randomString(8))
+	dirPath := filepath.Join(FlagDataDir, userName, dirName)
+	return dirName, dirPath, os.MkdirAll(dirPath, 0755)
+}
+
+// filesPath is URL path of the uploaded file
+func filesPath(userName, dirName, filename string) string {
+	if userName == "" {
+		return "/files/" + dirName + "/" + filename
+	}
+	return "/files/" + userName + "/" + dirName + "/" + filename
+}
+
 func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	owner, ok := authorized(w, r)
+	if !ok {
+		return
+	}
+	expires, err := uploadExpiry(r.Header.Get("X-Upload-Expire"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := uploadLimit(owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
 
-	if FlagToken != "" {
-		token := r.Header.Get("X-Upload-Token")
-		if token != FlagToken {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
 	}
 
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	reader, err := r.MultipartReader()
+	if err != nil {
 		http.Error(w, "Error parsing form", http.StatusBadRequest)
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Error getting file", http.StatusBadRequest)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "Error getting file", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			uploadError(w, err, "Error parsing form", http.StatusBadRequest, limit)
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		u, status, err := saveUpload(part, part.FileName(), owner.Name, limit)
+		part.Close()
+		if err != nil {
+			uploadError(w, err, err.Error(), status, limit)
+			return
+		}
+		u.UploaderIP = remoteIP(r)
+		u.Expires = expires
+		respondUpload(w, r, u, nil)
 		return
 	}
-	defer file.Close()
+}
 
-	unixTime := time.Now().Unix()
-	randStr := randomString(8)
-	dirName := fmt.Sprintf("%d_%s", unixTime, randStr)
+// saveUpload streams src to a new upload directory of the user and returns
+// its metadata, on error the directory is removed and HTTP status returned
+func saveUpload(src io.Reader, filename, userName string, limit int64) (*Upload, int, error) {
+	filename = sanitizeFilename(filename)
+	if !allowedExtension(filename) {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("File extension not allowed")
+	}
 
-	dirPath := filepath.Join(FlagDataDir, dirName)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		http.Error(w, "Error creating directory", http.StatusInternalServerError)
-		return
+	dirName, dirPath, err := newUploadDir(userName)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Error creating directory")
 	}
 
-	filePath := filepath.Join(dirPath, handler.Filename)
-	f, err := os.Create(filePath)
+	f, err := os.Create(filepath.Join(dirPath, filename))
 	if err != nil {
-		http.Error(w, "Error saving file", http.StatusInternalServerError)
-		return
+		os.RemoveAll(dirPath)
+		return nil, http.StatusInternalServerError, fmt.Errorf("Error saving file")
 	}
-	defer f.Close()
 
-	if _, err := io.Copy(f, file); err != nil {
-		http.Error(w, "Error writing file", http.StatusInternalServerError)
-		return
+	if limit > 0 {
+		src = io.LimitReader(src, limit+1)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && limit > 0 && n > limit {
+		err = &http.MaxBytesError{Limit: limit}
+	}
+	if err != nil {
+		os.RemoveAll(dirPath)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, err
+		}
+		return nil, http.StatusInternalServerError, fmt.Errorf("Error writing file")
 	}
 
-	fmt.Fprintf(w, "/files/%s/%s\n", dirName, handler.Filename)
+	return &Upload{
+		ID:       dirName,
+		User:     userName,
+		Filename: filename,
+		Path:     filesPath(userName, dirName, filename),
+		Size:     n,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		Created:  time.Now(),
+	}, http.StatusOK, nil
+}
+
+// uploadError responds 413 if the request body exceeded the limit of the
+// user (--max-size, user's max size or remaining quota)
+func uploadError(w http.ResponseWriter, err error, message string, status int, limit int64) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("File too large (max %s)", size_utils.Format(limit)), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, message, status)
 }
@@ -0,0 +1,172 @@
+package upload_server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sikalabs/slr/internal/size_utils"
+	"gopkg.in/yaml.v3"
+)
+
+// TokensConfig is --tokens-file, sizes are e.g. 500M or 5G, empty for
+// unlimited
+//
+//	Tokens:
+//	  - Token: 6f1c...
+//	    User: alice
+//	    Quota: 5G
+//	    MaxSize: 1G
+type TokensConfig struct {
+	Tokens []struct {
+		Token   string `yaml:"Token"`
+		User    string `yaml:"User"`
+		Quota   string `yaml:"Quota"`
+		MaxSize string `yaml:"MaxSize"`
+	} `yaml:"Tokens"`
+}
+
+// user is the authenticated uploader, admin (--token) and anonymous
+// uploads (no --token nor --tokens-file) have empty name and are stored
+// in the root of --data-dir
+type user struct {
+	Name    string
+	Quota   int64
+	MaxSize int64
+	Admin   bool
+}
+
+var validUserName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// users maps token to user, it's replaced on SIGHUP
+var users atomic.Pointer[map[string]*user]
+
+func loadTokens(path string) (map[string]*user, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config TokensConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	tokens := map[string]*user{}
+	quotas := map[string]int64{}
+	for i, t := range config.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("%s: token #%d is empty", path, i+1)
+		}
+		if !validUserName.MatchString(t.User) {
+			return nil, fmt.Errorf("%s: invalid user %q (a-z, 0-9, _ and -)", path, t.User)
+		}
+		if _, ok := tokens[t.Token]; ok {
+			return nil, fmt.Errorf("%s: duplicate token of user %s", path, t.User)
+		}
+		u := &user{Name: t.User}
+		if t.Quota != "" {
+			if u.Quota, err = size_utils.Parse(t.Quota); err != nil {
+				return nil, fmt.Errorf("%s: user %s: %w", path, t.User, err)
+			}
+		}
+		if t.MaxSize != "" {
+			if u.MaxSize, err = size_utils.Parse(t.MaxSize); err != nil {
+				return nil, fmt.Errorf("%s: user %s: %w", path, t.User, err)
+			}
+		}
+		// quota is shared by all tokens of the user
+		if q, ok := quotas[u.Name]; ok && q != u.Quota {
+			return nil, fmt.Errorf("%s: tokens of user %s have different quotas", path, t.User)
+		}
+		quotas[u.Name] = u.Quota
+		tokens[t.Token] = u
+	}
+	return tokens, nil
+}
+
+// reloadTokensOnSIGHUP keeps the current tokens if the file is invalid
+func reloadTokensOnSIGHUP(path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		tokens, err := loadTokens(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: reload of tokens failed, keeping previous: %v\n", err)
+			continue
+		}
+		users.Store(&tokens)
+		fmt.Printf("Reloaded %d tokens from %s\n", len(tokens), path)
+	}
+}
+
+// authenticate returns user of X-Upload-Token, nil if it's invalid
+func authenticate(r *http.Request) *user {
+	token := r.Header.Get("X-Upload-Token")
+	if FlagToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(FlagToken)) == 1 {
+		return &user{Admin: true}
+	}
+	tokens := users.Load()
+	if tokens == nil {
+		if FlagToken == "" {
+			return &user{}
+		}
+		return nil
+	}
+	if token == "" {
+		return nil
+	}
+	return (*tokens)[token]
+}
+
+// authorized responds 401 if the request has no valid token
+func authorized(w http.ResponseWriter, r *http.Request) (*user, bool) {
+	u := authenticate(r)
+	if u == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return u, true
+}
+
+var errQuotaExceeded = errors.New("Quota exceeded")
+
+// uploadLimit returns maximum size of the next upload of the user from
+// --max-size, user's MaxSize and remaining quota (0 for unlimited),
+// unfinished resumable uploads are counted with their full length
+func uploadLimit(u *user) (int64, error) {
+	limit := maxSize
+	if u.MaxSize > 0 && (limit == 0 || u.MaxSize < limit) {
+		limit = u.MaxSize
+	}
+	if u.Quota > 0 {
+		remaining := u.Quota - uploads.Usage(u.Name) - resumableReserved(u.Name)
+		if remaining <= 0 {
+			return 0, errQuotaExceeded
+		}
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	return limit, nil
+}
+
+// quotaOf returns quota of the user from the current tokens, 0 for
+// unlimited
+func quotaOf(name string) int64 {
+	tokens := users.Load()
+	if name == "" || tokens == nil {
+		return 0
+	}
+	for _, u := range *tokens {
+		if u.Name == name {
+			return u.Quota
+		}
+	}
+	return 0
+}
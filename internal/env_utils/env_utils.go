@@ -0,0 +1,17 @@
+package env_utils
+
+import (
+	"os"
+	"regexp"
+)
+
+var varRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Expand replaces only ${VAR} with value of environment variable, unlike
+// os.ExpandEnv it keeps $VAR and lone $ untouched, so passwords, regexps
+// or shell commands in configs are not mangled
+func Expand(s string) string {
+	return varRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		return os.Getenv(varRegexp.FindStringSubmatch(m)[1])
+	})
+}
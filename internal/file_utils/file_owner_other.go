@@ -0,0 +1,17 @@
+//go:build !windows
+
+package file_utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileOwner returns uid and gid of existing file, -1 if not available
+func FileOwner(info os.FileInfo) (int, int) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1
+	}
+	return int(st.Uid), int(st.Gid)
+}
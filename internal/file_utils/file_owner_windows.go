@@ -0,0 +1,8 @@
+package file_utils
+
+import "os"
+
+// FileOwner returns -1, -1 on Windows, ownership is not tracked there
+func FileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
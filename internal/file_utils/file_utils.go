@@ -0,0 +1,94 @@
+package file_utils
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// WriteFileAtomic writes data to temp file in the same directory, applies
+// mode and owner and renames it over path, so readers never see partial
+// content. Empty owner or group keeps the current user/group.
+func WriteFileAtomic(path string, data []byte, mode os.FileMode, owner, group string) error {
+	uid, gid, err := LookupOwner(owner, group)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(tmpPath, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// LookupOwner resolves user and group names (or numeric IDs) to uid and
+// gid, -1 is returned for empty values (os.Chown keeps them unchanged)
+func LookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			u, err = user.LookupId(owner)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unknown user %s", owner)
+			}
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+		if group == "" {
+			gid, _ = strconv.Atoi(u.Gid)
+		}
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			g, err = user.LookupGroupId(group)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unknown group %s", group)
+			}
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	return uid, gid, nil
+}
+
+// ParseMode parses octal file mode like "0600", empty string returns def
+func ParseMode(s string, def os.FileMode) (os.FileMode, error) {
+	if s == "" {
+		return def, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %s: %w", s, err)
+	}
+	return os.FileMode(mode), nil
+}
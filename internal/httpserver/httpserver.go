@@ -0,0 +1,146 @@
+// Package httpserver runs HTTP servers of slr commands with the standard
+// flags (--listen, --tls-cert, --tls-key, --tls-self-signed, timeouts,
+// --access-log) and graceful shutdown on SIGTERM and SIGINT.
+//
+//	var FlagServer = httpserver.AddFlags(Cmd, httpserver.Options{Listen: ":8000"})
+//	...
+//	err := FlagServer.ListenAndServe(mux)
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Listen          string
+	TLSCert         string
+	TLSKey          string
+	TLSSelfSigned   bool
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	AccessLog       bool
+}
+
+// AddFlags registers server flags on cmd, defaults are taken from
+// defaults (zero timeouts mean no timeout, e.g. for large uploads)
+func AddFlags(cmd *cobra.Command, defaults Options) *Options {
+	o := &Options{}
+	if defaults.Listen == "" {
+		defaults.Listen = ":8000"
+	}
+	if defaults.IdleTimeout == 0 {
+		defaults.IdleTimeout = 2 * time.Minute
+	}
+	if defaults.ShutdownTimeout == 0 {
+		defaults.ShutdownTimeout = 10 * time.Second
+	}
+	cmd.Flags().StringVar(&o.Listen, "listen", defaults.Listen, "Listen address")
+	cmd.Flags().StringVar(&o.TLSCert, "tls-cert", "", "TLS certificate file (with --tls-key)")
+	cmd.Flags().StringVar(&o.TLSKey, "tls-key", "", "TLS key file")
+	cmd.Flags().BoolVar(&o.TLSSelfSigned, "tls-self-signed", false, "Serve TLS with generated self-signed certificate")
+	cmd.Flags().DurationVar(&o.ReadTimeout, "read-timeout", defaults.ReadTimeout, "Timeout of reading a request including body (0 for none)")
+	cmd.Flags().DurationVar(&o.WriteTimeout, "write-timeout", defaults.WriteTimeout, "Timeout of writing a response (0 for none)")
+	cmd.Flags().DurationVar(&o.IdleTimeout, "idle-timeout", defaults.IdleTimeout, "Timeout of idle keep-alive connections")
+	cmd.Flags().DurationVar(&o.ShutdownTimeout, "shutdown-timeout", defaults.ShutdownTimeout, "Time for in-flight requests on shutdown")
+	cmd.Flags().BoolVar(&o.AccessLog, "access-log", true, "Log requests to stdout as JSON")
+	cmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+	cmd.MarkFlagsMutuallyExclusive("tls-cert", "tls-self-signed")
+	return o
+}
+
+func (o *Options) TLS() bool {
+	return o.TLSCert != "" || o.TLSSelfSigned
+}
+
+// URL returns local URL of the server for messages, e.g.
+// http://127.0.0.1:8000
+func (o *Options) URL() string {
+	scheme := "http"
+	if o.TLS() {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(o.Listen)
+	if err != nil {
+		return scheme + "://" + o.Listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// ListenAndServe serves handler until SIGTERM or SIGINT
+func (o *Options) ListenAndServe(handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return o.Serve(ctx, handler)
+}
+
+// Serve serves handler until ctx is done, then it shuts down gracefully,
+// in-flight requests have --shutdown-timeout to finish
+func (o *Options) Serve(ctx context.Context, handler http.Handler) error {
+	if o.AccessLog {
+		handler = accessLog(handler)
+	}
+	server := &http.Server{
+		Addr:              o.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       o.ReadTimeout,
+		WriteTimeout:      o.WriteTimeout,
+		IdleTimeout:       o.IdleTimeout,
+	}
+
+	if o.TLSSelfSigned {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := net.Listen("tcp", o.Listen)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Listen on %s, see %s\n", o.Listen, o.URL())
+
+	errCh := make(chan error, 1)
+	go func() {
+		if o.TLS() {
+			errCh <- server.ServeTLS(listener, o.TLSCert, o.TLSKey)
+		} else {
+			errCh <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package k8s builds Kubernetes clients for slr commands from the standard
+// flags (--kubeconfig, --context, --namespace, --as, --as-group, --qps,
+// --burst) with in-cluster fallback.
+//
+//	var K8s = k8s.AddFlags(Cmd)
+//	...
+//	clientset, err := K8s.Clientset()
+//	namespace, err := K8s.GetNamespace()
+//
+// Tests can inject client-go/kubernetes/fake with WithClientset.
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type Options struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
+	As         string
+	AsGroups   []string
+	QPS        float32
+	Burst      int
+
+	clientset kubernetes.Interface
+}
+
+// AddFlags registers connection flags and -n/--namespace (default:
+// namespace of the current context) on cmd
+func AddFlags(cmd *cobra.Command) *Options {
+	o := AddClusterFlags(cmd)
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "Kubernetes namespace (default: namespace of the current context)")
+	return o
+}
+
+// AddClusterFlags registers connection flags without --namespace, for
+// cluster scoped commands and commands with their own namespace semantics
+// (e.g. empty for all namespaces)
+func AddClusterFlags(cmd *cobra.Command) *Options {
+	o := &Options{}
+	cmd.Flags().StringVar(&o.Kubeconfig, "kubeconfig", "", "Path to kubeconfig (default: $KUBECONFIG or ~/.kube/config, in-cluster config in a pod)")
+	cmd.Flags().StringVar(&o.Context, "context", "", "Kubeconfig context (default: current context)")
+	cmd.Flags().StringVar(&o.As, "as", "", "Username to impersonate")
+	cmd.Flags().StringSliceVar(&o.AsGroups, "as-group", nil, "Group to impersonate, can be repeated")
+	cmd.Flags().Float32Var(&o.QPS, "qps", 0, "Kubernetes API client QPS (0 for client-go default)")
+	cmd.Flags().IntVar(&o.Burst, "burst", 0, "Kubernetes API client burst (0 for client-go default)")
+	return o
+}
+
+// WithClientset makes Clientset return the given client, it's meant for
+// kubernetes/fake in tests
+func (o *Options) WithClientset(clientset kubernetes.Interface) *Options {
+	o.clientset = clientset
+	return o
+}
+
+func (o *Options) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.Kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{CurrentContext: o.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+}
+
+// RESTConfig returns in-cluster config when running in a pod and neither
+// --kubeconfig nor --context is set, kubeconfig config otherwise
+func (o *Options) RESTConfig() (*rest.Config, error) {
+	var config *rest.Config
+	if o.Kubeconfig == "" && o.Context == "" {
+		config, _ = rest.InClusterConfig()
+	}
+	if config == nil {
+		var err error
+		config, err = o.clientConfig().ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+		}
+	}
+
+	if o.As != "" || len(o.AsGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{UserName: o.As, Groups: o.AsGroups}
+	}
+	if o.QPS > 0 {
+		config.QPS = o.QPS
+	}
+	if o.Burst > 0 {
+		config.Burst = o.Burst
+	}
+	// client-go requires burst when QPS is set
+	if config.QPS > 0 && config.Burst == 0 {
+		config.Burst = max(int(2*config.QPS), 1)
+	}
+	return config, nil
+}
+
+func (o *Options) Clientset() (kubernetes.Interface, error) {
+	if o.clientset != nil {
+		return o.clientset, nil
+	}
+
+	config, err := o.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	o.clientset = clientset
+	return clientset, nil
+}
+
+// GetNamespace returns --namespace, namespace of the context or of the
+// service account in a pod, "default" if none of them is set
+func (o *Options) GetNamespace() (string, error) {
+	if o.Namespace != "" {
+		return o.Namespace, nil
+	}
+	namespace, _, err := o.clientConfig().Namespace()
+	if err != nil {
+		return "", err
+	}
+	return namespace, nil
+}
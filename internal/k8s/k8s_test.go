@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: c
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: u
+  user:
+    token: x
+contexts:
+- name: dev
+  context:
+    cluster: c
+    user: u
+    namespace: dev
+- name: bare
+  context:
+    cluster: c
+    user: u
+`
+
+func writeKubeconfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetNamespace(t *testing.T) {
+	kubeconfig := writeKubeconfig(t)
+	for _, tc := range []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"flag", Options{Kubeconfig: kubeconfig, Namespace: "prod"}, "prod"},
+		{"current context", Options{Kubeconfig: kubeconfig}, "dev"},
+		{"context without namespace", Options{Kubeconfig: kubeconfig, Context: "bare"}, "default"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.options.GetNamespace()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("GetNamespace() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithClientset(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev"},
+	})
+	options := (&Options{Kubeconfig: "/nonexistent"}).WithClientset(clientset)
+
+	got, err := options.Clientset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != clientset {
+		t.Fatal("Clientset() didn't return injected clientset")
+	}
+	if _, err := got.CoreV1().Namespaces().Get(context.Background(), "dev", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
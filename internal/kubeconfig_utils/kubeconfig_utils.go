@@ -0,0 +1,79 @@
+package kubeconfig_utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Path returns kubeconfig file which should be modified, it honors
+// $KUBECONFIG the same way as kubectl does (first existing file wins)
+func Path() string {
+	return clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+}
+
+// Merge adds clusters, users and contexts from config to the kubeconfig
+// file returned by Path. Entries with the same name are replaced. The
+// previous file is backed up next to it, the backup path is returned
+// (empty if there was no previous file).
+func Merge(config *clientcmdapi.Config, currentContext string) (string, error) {
+	path := Path()
+
+	existing := clientcmdapi.NewConfig()
+	if _, err := os.Stat(path); err == nil {
+		existing, err = clientcmd.LoadFromFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to load kubeconfig %s: %w", path, err)
+		}
+	}
+
+	for name, cluster := range config.Clusters {
+		existing.Clusters[name] = cluster
+	}
+	for name, authInfo := range config.AuthInfos {
+		existing.AuthInfos[name] = authInfo
+	}
+	for name, context := range config.Contexts {
+		existing.Contexts[name] = context
+	}
+	if currentContext != "" {
+		existing.CurrentContext = currentContext
+	}
+
+	backup, err := Backup(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := clientcmd.WriteToFile(*existing, path); err != nil {
+		return "", fmt.Errorf("failed to write kubeconfig %s: %w", path, err)
+	}
+
+	return backup, nil
+}
+
+// Backup copies file to <path>.backup-<timestamp>, missing file is not
+// an error and empty string is returned
+func Backup(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	backup := path + ".backup-" + time.Now().Format("20060102-150405")
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to backup kubeconfig %s: %w", path, err)
+	}
+
+	return backup, nil
+}
@@ -0,0 +1,187 @@
+package kubeconfig_utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Keys of kubeconfig secret stored in Vault. Binary data (CA, client
+// certificate and key) are base64 encoded, exec args and env are JSON.
+const (
+	KeyClusterName           = "KUBERNETES_CLUSTER_NAME"
+	KeyServer                = "KUBERNETES_SERVER"
+	KeyCA                    = "KUBERNETES_CA"
+	KeyInsecureSkipTLSVerify = "KUBERNETES_INSECURE_SKIP_TLS_VERIFY"
+	KeyNamespace             = "KUBERNETES_NAMESPACE"
+	KeyToken                 = "KUBERNETES_TOKEN"
+	KeyClientCertificate     = "KUBERNETES_CLIENT_CERTIFICATE"
+	KeyClientKey             = "KUBERNETES_CLIENT_KEY"
+	KeyExecCommand           = "KUBERNETES_EXEC_COMMAND"
+	KeyExecArgs              = "KUBERNETES_EXEC_ARGS"
+	KeyExecEnv               = "KUBERNETES_EXEC_ENV"
+	KeyExecAPIVersion        = "KUBERNETES_EXEC_API_VERSION"
+)
+
+const defaultExecAPIVersion = "client.authentication.k8s.io/v1"
+
+// FromVaultData builds kubeconfig with one cluster, user and context,
+// all named contextName (defaults to KUBERNETES_CLUSTER_NAME). Namespace
+// overrides KUBERNETES_NAMESPACE if set.
+func FromVaultData(data map[string]string, contextName, namespace string) (*clientcmdapi.Config, error) {
+	if contextName == "" {
+		contextName = data[KeyClusterName]
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("%s is missing in secret, use custom context name", KeyClusterName)
+	}
+	if data[KeyServer] == "" {
+		return nil, fmt.Errorf("%s is missing in secret", KeyServer)
+	}
+	if namespace == "" {
+		namespace = data[KeyNamespace]
+	}
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = data[KeyServer]
+	cluster.InsecureSkipTLSVerify = data[KeyInsecureSkipTLSVerify] == "true"
+	if data[KeyCA] != "" {
+		ca, err := base64.StdEncoding.DecodeString(data[KeyCA])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", KeyCA, err)
+		}
+		cluster.CertificateAuthorityData = ca
+	}
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	switch {
+	case data[KeyToken] != "":
+		authInfo.Token = data[KeyToken]
+	case data[KeyClientCertificate] != "":
+		cert, err := base64.StdEncoding.DecodeString(data[KeyClientCertificate])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", KeyClientCertificate, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(data[KeyClientKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", KeyClientKey, err)
+		}
+		authInfo.ClientCertificateData = cert
+		authInfo.ClientKeyData = key
+	case data[KeyExecCommand] != "":
+		exec := &clientcmdapi.ExecConfig{
+			Command:         data[KeyExecCommand],
+			APIVersion:      data[KeyExecAPIVersion],
+			InteractiveMode: clientcmdapi.IfAvailableExecInteractiveMode,
+		}
+		if exec.APIVersion == "" {
+			exec.APIVersion = defaultExecAPIVersion
+		}
+		if data[KeyExecArgs] != "" {
+			if err := json.Unmarshal([]byte(data[KeyExecArgs]), &exec.Args); err != nil {
+				return nil, fmt.Errorf("failed to parse %s (JSON array expected): %w", KeyExecArgs, err)
+			}
+		}
+		if data[KeyExecEnv] != "" {
+			env := map[string]string{}
+			if err := json.Unmarshal([]byte(data[KeyExecEnv]), &env); err != nil {
+				return nil, fmt.Errorf("failed to parse %s (JSON object expected): %w", KeyExecEnv, err)
+			}
+			for _, name := range sortedKeys(env) {
+				exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: name, Value: env[name]})
+			}
+		}
+		authInfo.Exec = exec
+	default:
+		return nil, fmt.Errorf("no credentials in secret, one of %s, %s or %s is required", KeyToken, KeyClientCertificate, KeyExecCommand)
+	}
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = contextName
+	context.AuthInfo = contextName
+	context.Namespace = namespace
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[contextName] = cluster
+	config.AuthInfos[contextName] = authInfo
+	config.Contexts[contextName] = context
+	config.CurrentContext = contextName
+
+	return config, nil
+}
+
+// ToVaultData converts context from kubeconfig to the Vault schema,
+// config must be flattened (no references to files)
+func ToVaultData(config *clientcmdapi.Config, contextName string) (map[string]string, error) {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %s not found in kubeconfig", contextName)
+	}
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s not found in kubeconfig", context.Cluster)
+	}
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("user %s not found in kubeconfig", context.AuthInfo)
+	}
+
+	data := map[string]string{
+		KeyClusterName: contextName,
+		KeyServer:      cluster.Server,
+	}
+	if len(cluster.CertificateAuthorityData) > 0 {
+		data[KeyCA] = base64.StdEncoding.EncodeToString(cluster.CertificateAuthorityData)
+	}
+	if cluster.InsecureSkipTLSVerify {
+		data[KeyInsecureSkipTLSVerify] = "true"
+	}
+	if context.Namespace != "" {
+		data[KeyNamespace] = context.Namespace
+	}
+
+	switch {
+	case authInfo.Token != "":
+		data[KeyToken] = authInfo.Token
+	case len(authInfo.ClientCertificateData) > 0:
+		data[KeyClientCertificate] = base64.StdEncoding.EncodeToString(authInfo.ClientCertificateData)
+		data[KeyClientKey] = base64.StdEncoding.EncodeToString(authInfo.ClientKeyData)
+	case authInfo.Exec != nil:
+		data[KeyExecCommand] = authInfo.Exec.Command
+		data[KeyExecAPIVersion] = authInfo.Exec.APIVersion
+		if len(authInfo.Exec.Args) > 0 {
+			args, err := json.Marshal(authInfo.Exec.Args)
+			if err != nil {
+				return nil, err
+			}
+			data[KeyExecArgs] = string(args)
+		}
+		if len(authInfo.Exec.Env) > 0 {
+			env := map[string]string{}
+			for _, v := range authInfo.Exec.Env {
+				env[v.Name] = v.Value
+			}
+			envJSON, err := json.Marshal(env)
+			if err != nil {
+				return nil, err
+			}
+			data[KeyExecEnv] = string(envJSON)
+		}
+	default:
+		return nil, fmt.Errorf("user %s has no supported credentials (token, client certificate or exec)", context.AuthInfo)
+	}
+
+	return data, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
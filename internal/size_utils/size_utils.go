@@ -0,0 +1,50 @@
+package size_utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// Parse parses size in bytes with optional binary unit suffix K, M, G or
+// T (KB, KiB, ... are accepted too, all are powers of 1024), e.g. "512M"
+// or "1.5G"
+func Parse(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+	if v == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit := int64(1)
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(v, u.suffix); ok {
+			v, unit = n, u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// Format returns size with binary unit, e.g. 1.5G
+func Format(size int64) string {
+	for _, u := range units {
+		if size >= u.size {
+			return strconv.FormatFloat(float64(size)/float64(u.size), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
@@ -0,0 +1,108 @@
+package vault_utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// NewClient creates Vault client for given address. If token is empty,
+// VAULT_TOKEN or ~/.vault-token (written by `vault login`) is used.
+func NewClient(vaultAddr, token string) (*api.Client, error) {
+	config := api.DefaultConfig()
+	if vaultAddr != "" {
+		config.Address = vaultAddr
+	}
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		token, err = getTokenFromFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	client.SetToken(token)
+
+	return client, nil
+}
+
+// SplitPath splits secret path in format <mount>/<secret-path>
+func SplitPath(secretPath string) (string, string, error) {
+	parts := strings.SplitN(strings.Trim(secretPath, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("vault path must be in format <mount>/<secret-path>, got: %s", secretPath)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ReadKV2 reads KV2 secret and returns its string values
+func ReadKV2(client *api.Client, secretPath string) (map[string]string, error) {
+	mount, path, err := SplitPath(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.KVv2(mount).Get(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("%w at path: %s", api.ErrSecretNotFound, secretPath)
+	}
+
+	output := make(map[string]string)
+	for key, value := range secret.Data {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value type of key %s in secret %s", key, secretPath)
+		}
+		output[key] = s
+	}
+	return output, nil
+}
+
+// WriteKV2 writes KV2 secret, previous version is kept in secret history
+func WriteKV2(client *api.Client, secretPath string, data map[string]string) error {
+	mount, path, err := SplitPath(secretPath)
+	if err != nil {
+		return err
+	}
+
+	payload := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		payload[key] = value
+	}
+
+	_, err = client.KVv2(mount).Put(context.Background(), path, payload)
+	return err
+}
+
+// IsNotFound reports whether err means that the secret doesn't exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, api.ErrSecretNotFound)
+}
+
+func getTokenFromFile() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	token, err := os.ReadFile(filepath.Join(homeDir, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("no vault token found, use VAULT_TOKEN or `vault login`: %w", err)
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}